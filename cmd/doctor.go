@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/bonaysoft/notion-md-gen/generator"
+
+	"github.com/spf13/cobra"
+)
+
+var doctorJobs []string
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "check the access of the integration to Notion and the schema of the databases, with the fixes",
+	Long: `Check the access of the integration to Notion and the schema of the databases:
+NOTION_SECRET, the sharing of the databases or the root pages with the integration, the filterProp
+and its options, and the Name title property. Nothing is written to Notion, so the Update content capability
+required by the status and the write-back is not verified: it's printed as "?" to be checked by yourself,
+a run without failures doesn't mean it's enabled. Every failed check prints how to fix it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := generator.Doctor(loadConfig(), generator.Options{Jobs: doctorJobs}, os.Stdout); err != nil {
+			exit(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().StringSliceVar(&doctorJobs, "job", nil, "the names of the sync jobs to check (default is all)")
+}
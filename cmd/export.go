@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"github.com/bonaysoft/notion-md-gen/generator"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportJobs   []string
+	exportOutput string
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export <notion-url-or-id>",
+	Short: "render a single page, e.g. for debugging a conversion or a one-off export",
+	Example: `  notion-md-gen export https://www.notion.so/workspace/My-Post-1a2b3c4d5e6f47a8b9c0d1e2f3a4b5c6
+  notion-md-gen export 1a2b3c4d-5e6f-47a8-b9c0-d1e2f3a4b5c6 -o post.md
+  notion-md-gen export 1a2b3c4d5e6f47a8b9c0d1e2f3a4b5c6 -o -`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := generator.Export(loadConfig(), generator.Options{Jobs: exportJobs}, args[0], exportOutput); err != nil {
+			exit(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringSliceVar(&exportJobs, "job", nil, "the name of the sync job whose settings render the page (default is the first)")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "the file to write, - for the stdout (default is the file of the page in postSavePath)")
+}
@@ -22,20 +22,37 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"os"
+
 	"github.com/bonaysoft/notion-md-gen/generator"
 
 	"github.com/spf13/cobra"
 )
 
+var initOpts generator.InitOptions
+
 // initCmd represents the init command
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "init the config",
+	Long: `Create notion-md-gen.yaml and .env in the current directory, the existing files are kept unless --force.
+The shortcodeSyntax and the paths are detected from the static site generator of the site.
+With a token, by --token or NOTION_SECRET, the database and its status values are picked from the ones shared with the integration.
+With --shortcodes, the shortcodes rendered for the static site generator are scaffolded too.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return generator.DefaultConfigInit()
+		// the .env created by a previous init without a token holds the placeholder, which isn't a secret
+		if initOpts.Token == "" && os.Getenv("NOTION_SECRET") != generator.PlaceholderSecret {
+			initOpts.Token = os.Getenv("NOTION_SECRET")
+		}
+		initOpts.In, initOpts.Out = os.Stdin, os.Stdout
+		return generator.Init(initOpts)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(initCmd)
+
+	initCmd.Flags().BoolVar(&initOpts.Force, "force", false, "overwrite the existing config files")
+	initCmd.Flags().BoolVar(&initOpts.Shortcodes, "shortcodes", false, "also scaffold the shortcodes of the static site generator, see the scaffold command")
+	initCmd.Flags().StringVar(&initOpts.Token, "token", "", "the secret of the integration (default is $NOTION_SECRET)")
 }
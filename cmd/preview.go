@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/bonaysoft/notion-md-gen/generator"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	previewJobs []string
+	previewAddr string
+)
+
+// previewCmd represents the preview command
+var previewCmd = &cobra.Command{
+	Use:   "preview <page-id|url>",
+	Short: "render a page as HTML on localhost, with its markdown, front matter and warnings side by side",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		if err := generator.Preview(ctx, loadConfig(), generator.Options{Jobs: previewJobs}, args[0], previewAddr); err != nil {
+			exit(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(previewCmd)
+
+	previewCmd.Flags().StringSliceVar(&previewJobs, "job", nil, "the name of the sync job whose settings render the page (default is the first)")
+	previewCmd.Flags().StringVar(&previewAddr, "addr", "localhost:8080", "the address to serve the preview on")
+}
@@ -1,18 +1,25 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
-	"log"
 	"os"
 
 	"github.com/bonaysoft/notion-md-gen/generator"
+	"github.com/bonaysoft/notion-md-gen/pkg/logger"
 
 	"github.com/joho/godotenv"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-var cfgFile string
+var (
+	cfgFile   string
+	opts      generator.Options
+	verbose   bool
+	quiet     bool
+	logFormat string
+)
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -21,15 +28,53 @@ var rootCmd = &cobra.Command{
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	Run: func(cmd *cobra.Command, args []string) {
-		var config generator.Config
-		if err := viper.Unmarshal(&config); err != nil {
-			log.Fatal(err)
+		if err := generator.Run(loadConfig(), opts); err != nil {
+			exit(err)
 		}
+	},
+}
+
+// loadConfig loads and validates the config file found by viper, it exits on errors.
+func loadConfig() generator.Config {
+	config, err := generator.LoadConfig(viper.GetViper())
+	if err != nil {
+		exit(generator.Errors{{Kind: generator.ErrorConfig, Err: err}})
+	}
+	if errs := config.Validate(); len(errs) > 0 {
+		exit(errs)
+	}
+
+	return config
+}
 
-		if err := generator.Run(config); err != nil {
-			log.Println(err)
+// exitCodes are the exit codes by the kind of the failures, 1 is left for the usage errors.
+var exitCodes = map[generator.ErrorKind]int{
+	generator.ErrorConfig: 2,
+	generator.ErrorAuth:   3,
+	generator.ErrorAPI:    4,
+	generator.ErrorRender: 5,
+}
+
+// exit logs the errors and exits with the code of the most severe error.
+func exit(err error) {
+	var errs generator.Errors
+	if !errors.As(err, &errs) {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	for _, e := range errs {
+		fields := []interface{}{"kind", e.Kind}
+		if e.Job != "" {
+			fields = append(fields, "job", e.Job)
 		}
-	},
+		if e.Page != "" {
+			fields = append(fields, "page", e.Page)
+		}
+		logger.Error(e.Err.Error(), fields...)
+	}
+	logger.Error(fmt.Sprintf("%d error(s) occurred", len(errs)))
+	os.Exit(exitCodes[errs.Kind()])
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -42,9 +87,35 @@ func Execute() {
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
+	cobra.OnInitialize(initLogger, initConfig)
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is notion-md-gen.yaml)")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "print the debug logs, e.g. every Notion API call")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "only print the warnings and the errors")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "the format of the logs: text or json")
+	rootCmd.Flags().StringSliceVar(&opts.Jobs, "job", nil, "the names of the sync jobs to run (default is all)")
+	rootCmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "print what would change without writing files or updating Notion, incl. the stale files no page generates")
+	rootCmd.Flags().BoolVar(&opts.Diff, "diff", false, "print the unified diffs of the changed files in a dry run")
+	rootCmd.Flags().BoolVar(&opts.FailFast, "fail-fast", false, "stop at the first failing page instead of continuing with the others")
+	rootCmd.Flags().StringVar(&opts.Report, "report", "", "write a JSON report of the run to the file, e.g. report.json")
+	rootCmd.Flags().StringVar(&opts.Page, "page", "", "only sync the page of the ID or URL, regardless of the filter")
+}
+
+// initLogger sets up the logger by the flags.
+func initLogger() {
+	level := logger.LevelInfo
+	if verbose {
+		level = logger.LevelDebug
+	} else if quiet {
+		level = logger.LevelWarn
+	}
+
+	l, err := logger.New(os.Stderr, level, logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitCodes[generator.ErrorConfig])
+	}
+	logger.SetDefault(l)
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -58,13 +129,13 @@ func initConfig() {
 	}
 
 	if err := godotenv.Load(); err == nil {
-		fmt.Println("Load .env file")
+		logger.Debug("Load .env file")
 	}
 
 	viper.AutomaticEnv() // read in environment variables that match
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		logger.Info("Using config file", "file", viper.ConfigFileUsed())
 	}
 }
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/bonaysoft/notion-md-gen/generator"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var scaffoldOpts generator.ScaffoldOptions
+
+// scaffoldCmd represents the scaffold command
+var scaffoldCmd = &cobra.Command{
+	Use:   "scaffold",
+	Short: "write the shortcodes rendered by the shortcodeSyntax into the site",
+	Long: `Write the implementations of the bookmark, callout, columns and toc shortcodes rendered by the shortcodeSyntax
+into the site in the current directory: layouts/shortcodes of hugo, scripts of hexo, or the container plugins in .vuepress.
+The target is --target, or the shortcodeSyntax of the config file, or detected from the site.
+The existing files are kept unless --force.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if viper.ConfigFileUsed() != "" {
+			config := loadConfig()
+			scaffoldOpts.Targets = config.Targets
+			jobs, _ := config.SelectJobs()
+			for _, job := range jobs {
+				if scaffoldOpts.Target == "" && job.Markdown.ShortcodeSyntax != "" {
+					scaffoldOpts.Target = job.Markdown.ShortcodeSyntax
+				}
+			}
+		}
+
+		scaffoldOpts.Out = os.Stdout
+		if err := generator.Scaffold(scaffoldOpts); err != nil {
+			exit(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scaffoldCmd)
+
+	scaffoldCmd.Flags().StringVar(&scaffoldOpts.Target, "target", "", "the static site generator, e.g. hugo, hexo or vuepress")
+	scaffoldCmd.Flags().BoolVar(&scaffoldOpts.Force, "force", false, "overwrite the existing files")
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/bonaysoft/notion-md-gen/generator"
+
+	"github.com/spf13/cobra"
+)
+
+var serveOpts generator.ServeOptions
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "serve an authenticated HTTP endpoint triggering the syncs, e.g. from Notion automations or a chat bot",
+	Long: `Serve an authenticated HTTP endpoint triggering the syncs, e.g. from Notion automations or a chat bot.
+The requests must have the header "Authorization: Bearer <token>".
+
+  POST /sync    queue a sync, the query parameters "job" (repeatable) and "page" (ID or URL) are optional
+  GET  /status  the running, the queued and the last finished syncs
+  GET  /report  the JSON report of the last finished sync
+
+The syncs run one at a time. The hook runs after every sync with the JSON report in its stdin.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		if serveOpts.Token == "" {
+			serveOpts.Token = os.Getenv("NOTION_MD_GEN_TOKEN")
+		}
+		if err := generator.Serve(ctx, loadConfig(), serveOpts); err != nil {
+			exit(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVar(&serveOpts.Addr, "addr", ":8080", "the address to listen on")
+	serveCmd.Flags().StringVar(&serveOpts.Token, "token", "", "the bearer token of the requests (default is $NOTION_MD_GEN_TOKEN)")
+	serveCmd.Flags().StringVar(&serveOpts.Hook, "hook", "", "the shell command to run after every sync, e.g. to deploy the site")
+	serveCmd.Flags().StringVar(&serveOpts.Report, "report", "", "write the JSON report of every sync to the file")
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/bonaysoft/notion-md-gen/pkg/logger"
+	"github.com/bonaysoft/notion-md-gen/schema"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var printSchema bool
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "check the config file without running the sync",
+	Long: `Check the config file without running the sync: the unknown keys, the required settings,
+the formats of the IDs, the known values of the options and the writable paths.
+
+The JSON Schema of the config file, e.g. for the completion of the editors, is printed with --schema.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if printSchema {
+			_, _ = os.Stdout.Write(schema.JSON)
+			return
+		}
+
+		loadConfig()
+		logger.Info("✔ The config file is valid", "file", viper.ConfigFileUsed())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+
+	validateCmd.Flags().BoolVar(&printSchema, "schema", false, "print the JSON Schema of the config file")
+}
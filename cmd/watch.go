@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/bonaysoft/notion-md-gen/generator"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchJobs     []string
+	watchInterval time.Duration
+)
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "poll Notion and regenerate the edited pages, e.g. alongside hugo server",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		if err := generator.Watch(ctx, loadConfig(), generator.Options{Jobs: watchJobs}, watchInterval); err != nil {
+			exit(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+
+	watchCmd.Flags().StringSliceVar(&watchJobs, "job", nil, "the names of the sync jobs to watch (default is all)")
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", 10*time.Second, "the interval of polling Notion")
+}
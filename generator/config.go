@@ -1,9 +1,23 @@
 package generator
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/bonaysoft/notion-md-gen/pkg/storage"
+	"github.com/bonaysoft/notion-md-gen/pkg/tomarkdown"
+	"github.com/dstotijn/go-notion"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,49 +27,312 @@ type Notion struct {
 	FilterProp     string   `yaml:"filterProp"`
 	FilterValue    []string `yaml:"filterValue"`
 	PublishedValue string   `yaml:"publishedValue"`
+
+	// Optional: the filter is combined with the filterProp and filterValue above by and
+	Filter *Filter `yaml:"filter,omitempty"`
+	Sorts  []Sort  `yaml:"sorts,omitempty"`
+
+	// Optional: sync the root page and its descendants instead of the database
+	RootPageID string `yaml:"rootPageId,omitempty"`
+
+	// Optional: the properties written back to the pages after the sync
+	WriteBack WriteBack `yaml:"writeBack,omitempty"`
+}
+
+// WriteBack is the names of the properties written back to the pages after the sync, the empty ones are skipped.
+type WriteBack struct {
+	URLProp      string `yaml:"urlProp,omitempty"`      // url,rich_text: the public URL of the post, requires the postPublicLink
+	SyncedAtProp string `yaml:"syncedAtProp,omitempty"` // date,rich_text: the time of the last successful sync
+	SlugProp     string `yaml:"slugProp,omitempty"`     // rich_text: the slug of the generated post
+	ErrorProp    string `yaml:"errorProp,omitempty"`    // rich_text: the error of the last sync, cleared on success
 }
 
 type Markdown struct {
-	ShortcodeSyntax string `yaml:"shortcodeSyntax"` // hugo,hexo,vuepress
+	ShortcodeSyntax string `yaml:"shortcodeSyntax"` // hugo,hexo,vuepress or one of the targets
 	PageNamePrefix  string `yaml:"pageNamePrefix"`
 	PostSavePath    string `yaml:"postSavePath"`
 	ImageSavePath   string `yaml:"imageSavePath"`
 	ImagePublicLink string `yaml:"imagePublicLink"`
 
 	// Optional:
-	GroupByMonth bool   `yaml:"groupByMonth,omitempty"`
-	Template     string `yaml:"template,omitempty"`
+	GroupByMonth   bool             `yaml:"groupByMonth,omitempty"`
+	PostPublicLink string           `yaml:"postPublicLink,omitempty"` // e.g. https://example.com/posts
+	Template       string           `yaml:"template,omitempty"`
+	ImageStorage   string           `yaml:"imageStorage,omitempty"` // local,s3
+	S3             storage.S3Config `yaml:"s3,omitempty"`
+	Breadcrumb     string           `yaml:"breadcrumb,omitempty"`   // none,trail
+	ColumnLayout   string           `yaml:"columnLayout,omitempty"` // flatten,flex,shortcode
+
+	// Optional: export the child pages and the entries of the child databases as nested documents
+	ChildPages    bool   `yaml:"childPages,omitempty"`
+	ChildDatabase string `yaml:"childDatabase,omitempty"` // list,table
+	IndexFilename string `yaml:"indexFilename,omitempty"` // default by the shortcodeSyntax, e.g. _index.md for hugo
+	SidebarKey    string `yaml:"sidebarKey,omitempty"`    // default by the shortcodeSyntax, e.g. weight for hugo
+
+	targets []tomarkdown.Target // the targets of the config, set by SelectJobs
 }
 
 type Config struct {
 	Notion   `yaml:"notion"`
 	Markdown `yaml:"markdown"`
+
+	// Optional: run several sync jobs in one invocation, the notion and markdown sections above are ignored if set
+	Jobs []Job `yaml:"jobs,omitempty"`
+
+	// Optional: the shell commands run by the sync, and the commit of the generated files
+	Hooks Hooks `yaml:"hooks,omitempty"`
+	Git   Git   `yaml:"git,omitempty"`
+
+	// Optional: the static site generators added to the shortcodeSyntax, or replacing the built-in ones of the same names
+	Targets []Target `yaml:"targets,omitempty"`
+
+	targets []tomarkdown.Target // the targets above, built by LoadConfig
+}
+
+// Target is a static site generator rendered by the templates of a directory, see tomarkdown.Target.
+type Target struct {
+	Name      string   `yaml:"name"`
+	Templates string   `yaml:"templates"`          // the directory of the <block type>.gohtml templates, e.g. callout.gohtml
+	Blocks    []string `yaml:"blocks,omitempty"`   // the block types rendered by the templates, default is all the templates
+	Scaffold  string   `yaml:"scaffold,omitempty"` // the directory of the files written into the site by the scaffold command
+}
+
+// Job syncs a database or a page tree of Notion to a directory of markdown files.
+type Job struct {
+	Name     string `yaml:"name"`
+	Notion   `yaml:"notion"`
+	Markdown `yaml:"markdown"`
 }
 
-func DefaultConfigInit() error {
-	defaultCfg := &Config{
-		Notion: Notion{
-			DatabaseID:     "YOUR-NOTION-DATABASE-ID",
-			FilterProp:     "Status",
-			FilterValue:    []string{"Finished", "Published"},
-			PublishedValue: "Published",
-		},
-		Markdown: Markdown{
-			ShortcodeSyntax: "vuepress",
-			PostSavePath:    "posts/notion",
-			ImageSavePath:   "static/images/notion",
-			ImagePublicLink: "/images/notion",
-		},
-	}
-	out, err := yaml.Marshal(defaultCfg)
+// SelectJobs returns the jobs with the given names, or all the jobs if no name is given.
+// A config without jobs has the single job named "default" made of its notion and markdown sections.
+func (c Config) SelectJobs(names ...string) ([]Job, error) {
+	jobs := append([]Job{}, c.Jobs...)
+	if len(jobs) == 0 {
+		jobs = []Job{{Name: "default", Notion: c.Notion, Markdown: c.Markdown}}
+	}
+	for i := range jobs {
+		jobs[i].Markdown.targets = c.targets
+	}
+	if len(names) == 0 {
+		return jobs, nil
+	}
+
+	selected := make([]Job, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, job := range jobs {
+			if job.Name == name {
+				selected = append(selected, job)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown job: %s", name)
+		}
+	}
+
+	return selected, nil
+}
+
+// LoadConfig decodes the config read by viper, with the overrides of the environment variables.
+// A YAML config file is checked first, so that the errors point at the offending line of the file.
+func LoadConfig(v *viper.Viper) (Config, error) {
+	var config Config
+	filename := v.ConfigFileUsed()
+	if filename == "" {
+		return config, fmt.Errorf("config file not found, run `notion-md-gen init` to create one")
+	}
+
+	if ext := strings.ToLower(filepath.Ext(filename)); ext == ".yaml" || ext == ".yml" {
+		if err := checkConfigFile(filename); err != nil {
+			return config, fmt.Errorf("%s: %s", filename, err)
+		}
+	}
+	err := v.Unmarshal(&config, func(dc *mapstructure.DecoderConfig) {
+		dc.TagName = "yaml"
+		dc.DecodeHook = mapstructure.ComposeDecodeHookFunc(decodeFilterHook, dc.DecodeHook)
+	})
+	if err != nil {
+		return config, fmt.Errorf("%s: %s", filename, err)
+	}
+	if config.targets, err = buildTargets(config.Targets); err != nil {
+		return config, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	return config, nil
+}
+
+// checkConfigFile decodes the YAML config file strictly. The unknown keys are rejected, since a typo would silently
+// leave the setting empty, and so are the invalid filter and sorts.
+func checkConfigFile(filename string) error {
+	data, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return err
 	}
 
-	defer func() {
-		_ = ioutil.WriteFile(".env", []byte("NOTION_SECRET=xxxx"), 0644)
-		fmt.Println("Config file notion-md-gen.yaml and .env created, please edit them for yourself.")
-	}()
+	var config Config
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&config)
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		msgs := make([]string, 0, len(typeErr.Errors))
+		for _, msg := range typeErr.Errors {
+			if msg, ok := explainUnknownField(msg); ok {
+				msgs = append(msgs, msg)
+			}
+		}
+		if len(msgs) == 0 {
+			return nil
+		}
+		typeErr.Errors = msgs
+	}
+	if err != nil && err != io.EOF {
+		return errors.New(strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+
+	return nil
+}
+
+// decodeFilterHook decodes the filter through its YAML form, as the filter is kept as a yaml.Node.
+func decodeFilterHook(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if to != reflect.TypeOf(Filter{}) {
+		return data, nil
+	}
+
+	b, err := yaml.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var filter Filter
+	if err := yaml.Unmarshal(b, &filter); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// buildTargets returns the targets of the config, which the jobs can use as the shortcodeSyntax.
+// They're kept by the config instead of registered to tomarkdown, so that they don't outlive it.
+func buildTargets(targets []Target) ([]tomarkdown.Target, error) {
+	built := make([]tomarkdown.Target, 0, len(targets))
+	for i, t := range targets {
+		if _, err := os.Stat(t.Templates); err != nil {
+			return nil, fmt.Errorf("targets[%d].templates: %s", i, err)
+		}
+
+		target := tomarkdown.Target{Name: t.Name, Templates: os.DirFS(t.Templates)}
+		for _, block := range t.Blocks {
+			target.Blocks = append(target.Blocks, notion.BlockType(block))
+		}
+		if len(t.Blocks) == 0 {
+			filenames, _ := fs.Glob(target.Templates, "*.gohtml")
+			for _, filename := range filenames {
+				target.Blocks = append(target.Blocks, notion.BlockType(strings.TrimSuffix(filename, ".gohtml")))
+			}
+		}
+		if t.Scaffold != "" {
+			target.Scaffold = os.DirFS(t.Scaffold)
+		}
+
+		if err := target.Validate(); err != nil {
+			return nil, fmt.Errorf("targets[%d]: %s", i, err)
+		}
+		built = append(built, target)
+	}
+
+	return built, nil
+}
+
+// configTypes are the types of the config sections by their names in the errors of yaml.
+var configTypes = map[string]reflect.Type{
+	"generator.Config":    reflect.TypeOf(Config{}),
+	"generator.Notion":    reflect.TypeOf(Notion{}),
+	"generator.Markdown":  reflect.TypeOf(Markdown{}),
+	"generator.Job":       reflect.TypeOf(Job{}),
+	"generator.WriteBack": reflect.TypeOf(WriteBack{}),
+	"generator.Hooks":     reflect.TypeOf(Hooks{}),
+	"generator.Git":       reflect.TypeOf(Git{}),
+	"generator.Target":    reflect.TypeOf(Target{}),
+	"storage.S3Config":    reflect.TypeOf(storage.S3Config{}),
+}
 
-	return ioutil.WriteFile("notion-md-gen.yaml", out, fs.FileMode(0644))
+var unknownFieldPattern = regexp.MustCompile(`^(line \d+): field (\S+) not found in type (\S+)$`)
+
+// explainUnknownField rewrites the error of an unknown key, with the closest known key as a suggestion.
+// It returns false if the key only differs from a known key by its case, which viper accepts.
+func explainUnknownField(msg string) (string, bool) {
+	m := unknownFieldPattern.FindStringSubmatch(msg)
+	if m == nil {
+		return msg, true
+	}
+	msg = fmt.Sprintf("%s: unknown key %q", m[1], m[2])
+	if t, ok := configTypes[m[3]]; ok {
+		key := closestKey(m[2], yamlKeys(t))
+		if strings.EqualFold(key, m[2]) {
+			return "", false
+		}
+		if key != "" {
+			msg += fmt.Sprintf(", did you mean %q?", key)
+		}
+	}
+	return msg, true
+}
+
+// yamlKeys returns the yaml keys of the struct type.
+func yamlKeys(t reflect.Type) []string {
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if name != "" && name != "-" {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+// closestKey returns the key most similar to s, or empty if none is close enough to be a typo.
+func closestKey(s string, keys []string) string {
+	best, bestDistance := "", 3
+	for _, key := range keys {
+		if strings.EqualFold(s, key) {
+			return key
+		}
+		if d := editDistance(strings.ToLower(s), strings.ToLower(key)); d < bestDistance {
+			best, bestDistance = key, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance of the strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
 }
@@ -0,0 +1,222 @@
+package generator
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bonaysoft/notion-md-gen/pkg/storage"
+	"github.com/bonaysoft/notion-md-gen/pkg/tomarkdown"
+	"github.com/bonaysoft/notion-md-gen/schema"
+	"github.com/dstotijn/go-notion"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// loadConfigFile reads the config file by viper, like the commands do.
+func loadConfigFile(filename string) (Config, error) {
+	v := viper.New()
+	v.SetConfigFile(filename)
+	if err := v.ReadInConfig(); err != nil {
+		return Config{}, err
+	}
+	return LoadConfig(v)
+}
+
+func TestLoadConfig(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "notion-md-gen.yaml")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`notion:
+  DatabaseID: 1a2b3c4d5e6f47a8b9c0d1e2f3a4b5c6
+  filterValue: [Finished]
+  filter:
+    and:
+      - property: Stars
+        number: { greater_than_or_equal_to: 4.5 }
+      - property: Date
+        date: { on_or_before: "2022-01-25" }
+  sorts:
+    - property: Date
+      direction: descending
+markdown:
+  postSavePath: posts
+`), 0644))
+
+	t.Setenv("NOTION_MD_GEN_MARKDOWN_POSTSAVEPATH", "content/posts")
+	v := viper.New()
+	v.SetConfigFile(filename)
+	v.SetEnvPrefix("notion_md_gen")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	assert.NoError(t, v.ReadInConfig())
+	config, err := LoadConfig(v)
+	assert.NoError(t, err)
+	assert.Equal(t, "1a2b3c4d5e6f47a8b9c0d1e2f3a4b5c6", config.DatabaseID)
+	assert.Equal(t, []string{"Finished"}, config.FilterValue)
+	assert.Equal(t, "content/posts", config.PostSavePath)
+	assert.Equal(t, []Sort{{Property: "Date", Direction: "descending"}}, config.Sorts)
+	filter, err := config.Filter.Build(time.Now())
+	assert.NoError(t, err)
+	b, _ := json.Marshal(filter)
+	assert.JSONEq(t, `{"and":[
+		{"property":"Stars","number":{"greater_than_or_equal_to":4.5}},
+		{"property":"Date","date":{"on_or_before":"2022-01-25T00:00:00Z"}}
+	]}`, string(b))
+}
+
+func TestLoadConfigUnknownKey(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "notion-md-gen.yaml")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`notion:
+  databseId: 1a2b3c4d5e6f47a8b9c0d1e2f3a4b5c6
+markdown:
+  postSavePath: posts
+  colour: red
+`), 0644))
+
+	_, err := loadConfigFile(filename)
+	assert.EqualError(t, err, filename+`: unmarshal errors:
+  line 2: unknown key "databseId", did you mean "databaseId"?
+  line 5: unknown key "colour"`)
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	valid := Markdown{ShortcodeSyntax: "hugo", PostSavePath: filepath.Join(dir, "posts"), ImageSavePath: filepath.Join(dir, "static", "images")}
+	assert.Empty(t, Config{Notion: Notion{DatabaseID: "1a2b3c4d-5e6f-47a8-b9c0-d1e2f3a4b5c6"}, Markdown: valid}.Validate())
+
+	file := filepath.Join(dir, "file")
+	assert.NoError(t, ioutil.WriteFile(file, nil, 0644))
+	config := Config{
+		Jobs: []Job{
+			{Name: "blog", Notion: Notion{DatabaseID: "YOUR-NOTION-DATABASE-ID", FilterProp: "Status"}, Markdown: Markdown{
+				ShortcodeSyntax: "jekyll",
+				PostSavePath:    filepath.Join(file, "posts"),
+				ImageStorage:    "s3",
+				S3:              storage.S3Config{Endpoint: "https://s3.amazonaws.com"},
+			}},
+			{Name: "blog", Notion: Notion{RootPageID: "https://www.notion.so/Docs-1a2b3c4d5e6f47a8b9c0d1e2f3a4b5c6"}, Markdown: valid},
+			{Notion: Notion{WriteBack: WriteBack{URLProp: "URL"}}, Markdown: Markdown{Breadcrumb: "full", ImageSavePath: dir}},
+		},
+		Git: Git{Message: "{{.Pages"},
+	}
+
+	var messages []string
+	for _, err := range config.Validate() {
+		assert.Equal(t, ErrorConfig, err.Kind)
+		messages = append(messages, err.Job+": "+err.Err.Error())
+	}
+	assert.Equal(t, []string{
+		"blog: jobs[1].name is duplicated",
+		": jobs[2].name is required",
+		`blog: notion.databaseId: invalid ID "YOUR-NOTION-DATABASE-ID", copy the 32 characters ID from the URL of the page in Notion`,
+		"blog: notion.filterValue is required by the filterProp",
+		`blog: markdown.shortcodeSyntax: unknown value "jekyll" (want hugo, hexo, vuepress)`,
+		"blog: markdown.postSavePath: " + filepath.Join(file, "posts") + " isn't writable",
+		"blog: markdown.s3.bucket is required by the s3 image storage",
+		`blog: notion.rootPageId: "https://www.notion.so/Docs-1a2b3c4d5e6f47a8b9c0d1e2f3a4b5c6" is a URL, use its ID 1a2b3c4d5e6f47a8b9c0d1e2f3a4b5c6`,
+		": notion.databaseId or notion.rootPageId is required",
+		": markdown.postPublicLink is required by the notion.writeBack.urlProp",
+		`: markdown.breadcrumb: unknown value "full" (want none, trail)`,
+		": markdown.postSavePath is required",
+		": git.message is an invalid template: template: message:1: unclosed action",
+	}, messages)
+}
+
+func TestLoadConfigTargets(t *testing.T) {
+	dir := t.TempDir()
+	templates := filepath.Join(dir, "docusaurus")
+	assert.NoError(t, os.Mkdir(templates, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(templates, "callout.gohtml"), []byte(":::note\n{{rich2md .Callout.Text}}\n:::\n"), 0644))
+	filename := filepath.Join(dir, "notion-md-gen.yaml")
+	write := func(config string) {
+		assert.NoError(t, ioutil.WriteFile(filename, []byte(config), 0644))
+	}
+
+	write(`markdown:
+  shortcodeSyntax: docusaurus
+targets:
+  - name: docusaurus
+    templates: ` + templates + "\n")
+	config, err := loadConfigFile(filename)
+	assert.NoError(t, err)
+	config.DatabaseID, config.PostSavePath, config.ImageSavePath = "1a2b3c4d5e6f47a8b9c0d1e2f3a4b5c6", filepath.Join(dir, "posts"), filepath.Join(dir, "images")
+	assert.Empty(t, config.Validate())
+	jobs, _ := config.SelectJobs()
+	if assert.Len(t, jobs[0].Markdown.targets, 1) {
+		assert.Equal(t, []notion.BlockType{notion.BlockTypeCallout}, jobs[0].Markdown.targets[0].Blocks)
+	}
+	doc := newDocument(databasePage("page", "Hello"), jobs[0].Markdown)
+	doc.blocks = []notion.Block{{Object: "block", Type: notion.BlockTypeCallout, Callout: &notion.Callout{RichTextBlock: notion.RichTextBlock{Text: richText("Hi")}}}}
+	content, err := render(doc, nil, jobs[0].Markdown, nil, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), ":::note\nHi\n:::\n")
+
+	// the targets stay with their config
+	_, ok := tomarkdown.LookupTarget("docusaurus")
+	assert.False(t, ok)
+	config.targets = nil
+	assert.Len(t, config.Validate(), 1)
+
+	write(`targets:
+  - name: docusaurus
+    templates: ` + templates + `
+    blocks: [callout, bookmark]
+`)
+	_, err = loadConfigFile(filename)
+	assert.EqualError(t, err, filename+": targets[0]: target docusaurus: no template of the block bookmark")
+
+	write(`targets:
+  - name: docusaurus
+    templates: ` + filepath.Join(dir, "missing") + "\n")
+	_, err = loadConfigFile(filename)
+	assert.EqualError(t, err, filename+": targets[0].templates: stat "+filepath.Join(dir, "missing")+": no such file or directory")
+}
+
+// TestSchema checks that the JSON Schema has the same keys as the config.
+func TestSchema(t *testing.T) {
+	var doc struct {
+		Properties  map[string]json.RawMessage
+		Definitions map[string]json.RawMessage
+	}
+	assert.NoError(t, json.Unmarshal(schema.JSON, &doc))
+
+	var object struct {
+		Properties map[string]json.RawMessage
+	}
+	keys := func(raw json.RawMessage, path ...string) []string {
+		for _, key := range path {
+			object.Properties = nil
+			assert.NoError(t, json.Unmarshal(raw, &object))
+			raw = object.Properties[key]
+		}
+		object.Properties = nil
+		assert.NoError(t, json.Unmarshal(raw, &object))
+		names := make([]string, 0, len(object.Properties))
+		for name := range object.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+	sorted := func(t reflect.Type) []string {
+		names := yamlKeys(t)
+		sort.Strings(names)
+		return names
+	}
+
+	root, _ := json.Marshal(doc)
+	assert.Equal(t, sorted(reflect.TypeOf(Config{})), keys(root))
+	assert.Equal(t, sorted(reflect.TypeOf(Job{})), keys(doc.Definitions["job"]))
+	assert.Equal(t, sorted(reflect.TypeOf(Notion{})), keys(doc.Definitions["notion"]))
+	assert.Equal(t, sorted(reflect.TypeOf(WriteBack{})), keys(doc.Definitions["notion"], "writeBack"))
+	assert.Equal(t, sorted(reflect.TypeOf(Markdown{})), keys(doc.Definitions["markdown"]))
+	assert.Equal(t, sorted(reflect.TypeOf(storage.S3Config{})), keys(doc.Definitions["markdown"], "s3"))
+	assert.Equal(t, sorted(reflect.TypeOf(Hooks{})), keys(doc.Definitions["hooks"]))
+	assert.Equal(t, sorted(reflect.TypeOf(Git{})), keys(doc.Definitions["git"]))
+	assert.Equal(t, sorted(reflect.TypeOf(Target{})), keys(doc.Definitions["target"]))
+}
@@ -0,0 +1,257 @@
+package generator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bonaysoft/notion-md-gen/pkg/tomarkdown"
+	"github.com/dstotijn/go-notion"
+)
+
+type checkStatus string
+
+const (
+	checkOK         checkStatus = "✔"
+	checkWarn       checkStatus = "⚠"
+	checkFail       checkStatus = "✘"
+	checkUnverified checkStatus = "?" // the doctor can't check it, e.g. without writing to Notion
+)
+
+// checkResult is the result of a doctor check, the failed, the warned and the unverified checks have a fix.
+type checkResult struct {
+	Job    string
+	Status checkStatus
+	Msg    string
+	Fix    string
+	Kind   ErrorKind // the kind of the failure, for the exit code
+}
+
+// Doctor checks the access of the integration to the databases or the pages of the jobs, and their schema.
+// The results are printed with the fixes of the failed checks.
+func Doctor(config Config, opts Options, w io.Writer) error {
+	jobs, err := config.SelectJobs(opts.Jobs...)
+	if err != nil {
+		return Errors{configError(err)}
+	}
+
+	secret := os.Getenv("NOTION_SECRET")
+	results := diagnose(newBlockFetcher(secret, newHTTPClient()), secret, jobs)
+	var errs Errors
+	job := ""
+	for _, r := range results {
+		if r.Job != job && len(jobs) > 1 {
+			fmt.Fprintf(w, "== Job %s ==\n", r.Job)
+		}
+		job = r.Job
+		fmt.Fprintf(w, "%s %s\n", r.Status, r.Msg)
+		if r.Fix != "" {
+			fmt.Fprintf(w, "    fix: %s\n", r.Fix)
+		}
+		if r.Status == checkFail {
+			errs = append(errs, &Error{Kind: r.Kind, Job: r.Job, Err: errors.New(r.Msg)})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+const shareFix = "open it in Notion, click ••• > Connections (or Share) and add the integration; the pages inherit the access of their parent"
+
+// diagnose runs the checks, the checks of the jobs are skipped if the secret doesn't work.
+func diagnose(fetcher *blockFetcher, secret string, jobs []Job) []checkResult {
+	var results []checkResult
+	add := func(job string, status checkStatus, kind ErrorKind, fix string, format string, args ...interface{}) {
+		results = append(results, checkResult{Job: job, Status: status, Msg: fmt.Sprintf(format, args...), Fix: fix, Kind: kind})
+	}
+
+	if secret == "" {
+		add("", checkFail, ErrorAuth, "create an internal integration at https://www.notion.so/my-integrations, then set its secret as NOTION_SECRET in the environment or the .env file", "NOTION_SECRET isn't set")
+		return results
+	}
+	var bot struct {
+		Name string `json:"name"`
+	}
+	if err := fetcher.raw.do(http.MethodGet, "/users/me", nil, &bot); err != nil {
+		if errors.Is(err, notion.ErrUnauthorized) {
+			add("", checkFail, ErrorAuth, "copy the Internal Integration Secret from https://www.notion.so/my-integrations again", "NOTION_SECRET is invalid: %s", err)
+		} else {
+			add("", checkFail, ErrorAPI, "check the connection to https://api.notion.com", "couldn't reach the Notion API: %s", err)
+		}
+		return results
+	}
+	add("", checkOK, "", "", "NOTION_SECRET is valid, the integration is %q", bot.Name)
+
+	for _, job := range jobs {
+		for _, r := range diagnoseJob(fetcher, job) {
+			r.Job = job.Name
+			results = append(results, r)
+		}
+	}
+	return results
+}
+
+// databaseSchema is the schema of a database with the options of the properties,
+// which go-notion drops for the status type.
+type databaseSchema struct {
+	Properties map[string]struct {
+		Type        string           `json:"type"`
+		Select      *propertyOptions `json:"select"`
+		Status      *propertyOptions `json:"status"`
+		MultiSelect *propertyOptions `json:"multi_select"`
+	} `json:"properties"`
+}
+
+// propertyNames returns the sorted names of the properties.
+func (s databaseSchema) propertyNames() []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type propertyOptions struct {
+	Options []struct {
+		Name string `json:"name"`
+	} `json:"options"`
+}
+
+func diagnoseJob(fetcher *blockFetcher, job Job) []checkResult {
+	var results []checkResult
+	add := func(status checkStatus, kind ErrorKind, fix string, format string, args ...interface{}) {
+		results = append(results, checkResult{Status: status, Msg: fmt.Sprintf(format, args...), Fix: fix, Kind: kind})
+	}
+	accessFailed := func(what, id string, err error) []checkResult {
+		switch {
+		case errors.Is(err, notion.ErrObjectNotFound), errors.Is(err, notion.ErrRestrictedResource):
+			add(checkFail, ErrorAuth, shareFix, "the integration can't access the %s %s", what, id)
+		case errors.Is(err, notion.ErrValidation):
+			add(checkFail, ErrorConfig, "copy the ID from the URL of the "+what+" opened as a full page", "%s isn't the ID of a %s: %s", id, what, err)
+		default:
+			add(checkFail, ErrorAPI, "", "couldn't retrieve the %s %s: %s", what, id, err)
+		}
+		return results
+	}
+
+	n := job.Notion
+	if n.RootPageID != "" {
+		if err := fetcher.raw.do(http.MethodGet, "/pages/"+n.RootPageID, nil, &json.RawMessage{}); err != nil {
+			return accessFailed("page", n.RootPageID, err)
+		}
+		add(checkOK, "", "", "the integration can access the root page %s", n.RootPageID)
+		return results
+	}
+
+	var raw json.RawMessage
+	if err := fetcher.raw.do(http.MethodGet, "/databases/"+n.DatabaseID, nil, &raw); err != nil {
+		return accessFailed("database", n.DatabaseID, err)
+	}
+	var db notion.Database
+	var schema databaseSchema
+	if err := json.Unmarshal(raw, &db); err != nil {
+		add(checkFail, ErrorAPI, "", "couldn't parse the database: %s", err)
+		return results
+	}
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		add(checkFail, ErrorAPI, "", "couldn't parse the database: %s", err)
+		return results
+	}
+	add(checkOK, "", "", "the integration can access the database %q", tomarkdown.ConvertPlainText(db.Title))
+
+	names := schema.propertyNames()
+	if prop, ok := schema.Properties["Name"]; ok && prop.Type == string(notion.DBPropTypeTitle) {
+		add(checkOK, "", "", "the title property is Name")
+	} else {
+		for _, name := range names {
+			if schema.Properties[name].Type == string(notion.DBPropTypeTitle) {
+				add(checkWarn, "", "rename the property to Name, the templates and the child databases expect it", "the title property is %s, not Name", name)
+			}
+		}
+	}
+
+	if n.FilterProp != "" {
+		results = append(results, diagnoseFilterProp(schema, names, n)...)
+	}
+
+	if _, err := newWriteBack(db, job); err != nil {
+		add(checkFail, ErrorConfig, "create the property with the supported type in the database, or fix notion.writeBack", "write-back: %s", err)
+	} else if n.WriteBack != (WriteBack{}) {
+		add(checkOK, "", "", "the write-back properties exist with the supported types")
+	}
+
+	if n.PublishedValue != "" || n.WriteBack != (WriteBack{}) {
+		// the API doesn't tell the capabilities of the integration, and the doctor never writes to the database
+		add(checkUnverified, "", "make sure the Update content capability of the integration is enabled at https://www.notion.so/my-integrations",
+			"not verified: the status and the write-back require the Update content capability, which can't be checked without writing to Notion")
+	}
+
+	return results
+}
+
+// diagnoseFilterProp checks the type of the filterProp, and that the filterValue and the publishedValue are its options.
+func diagnoseFilterProp(schema databaseSchema, names []string, n Notion) []checkResult {
+	var results []checkResult
+	add := func(status checkStatus, fix string, format string, args ...interface{}) {
+		results = append(results, checkResult{Status: status, Msg: fmt.Sprintf(format, args...), Fix: fix, Kind: ErrorConfig})
+	}
+
+	prop, ok := schema.Properties[n.FilterProp]
+	if !ok {
+		add(checkFail, "create the property in the database, or set notion.filterProp to one of: "+strings.Join(names, ", "), "the filterProp %s isn't a property of the database", n.FilterProp)
+		return results
+	}
+
+	var options *propertyOptions
+	switch notion.DatabasePropertyType(prop.Type) {
+	case notion.DBPropTypeSelect:
+		options = prop.Select
+	case dbPropTypeStatus:
+		options = prop.Status
+	case notion.DBPropTypeMultiSelect:
+		options = prop.MultiSelect
+	case notion.DBPropTypeCheckbox:
+	default:
+		add(checkFail, "change the type of the property to select, status, checkbox or multi_select", "the filterProp %s has the unsupported type %s", n.FilterProp, prop.Type)
+		return results
+	}
+	add(checkOK, "", "the filterProp %s is a %s property", n.FilterProp, prop.Type)
+
+	values := append([]string{}, n.FilterValue...)
+	if n.PublishedValue != "" {
+		values = append(values, n.PublishedValue)
+	}
+	for _, value := range values {
+		if options == nil {
+			if _, err := strconv.ParseBool(value); err != nil {
+				add(checkFail, "use true or false for a checkbox", "%q isn't a value of the checkbox %s", value, n.FilterProp)
+			}
+			continue
+		}
+
+		known := make([]string, 0, len(options.Options))
+		found := false
+		for _, option := range options.Options {
+			known = append(known, option.Name)
+			found = found || option.Name == value
+		}
+		if !found {
+			add(checkFail, fmt.Sprintf("add the option to the property, or use one of: %s", strings.Join(known, ", ")), "%q isn't an option of %s", value, n.FilterProp)
+		}
+	}
+	if len(results) == 1 && len(values) > 0 {
+		add(checkOK, "", "the filterValue and the publishedValue are options of %s", n.FilterProp)
+	}
+
+	return results
+}
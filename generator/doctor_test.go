@@ -0,0 +1,60 @@
+package generator
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiagnose(t *testing.T) {
+	fetcher := newTestFetcher(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v1/users/me":
+			_, _ = w.Write([]byte(`{"object":"user","name":"Blog","type":"bot"}`))
+		case "GET /v1/databases/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa":
+			_, _ = w.Write([]byte(`{"object":"database","title":[{"type":"text","text":{"content":"Posts"},"plain_text":"Posts"}],"properties":{
+				"Title":{"type":"title","title":{}},
+				"Status":{"type":"status","status":{"options":[{"name":"Ready"},{"name":"Published"}]}},
+				"URL":{"type":"rich_text","rich_text":{}}
+			}}`))
+		default:
+			if r.Method != http.MethodGet {
+				t.Errorf("unexpected write: %s %s", r.Method, r.URL.Path)
+			}
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"object":"error","status":404,"code":"object_not_found","message":"Could not find database."}`))
+		}
+	}))
+	statuses := func(results []checkResult) []string {
+		lines := make([]string, 0, len(results))
+		for _, r := range results {
+			lines = append(lines, string(r.Status)+" "+r.Job+": "+r.Msg)
+		}
+		return lines
+	}
+
+	assert.Equal(t, []string{"✘ : NOTION_SECRET isn't set"}, statuses(diagnose(fetcher, "", nil)))
+
+	jobs := []Job{
+		{Name: "blog", Notion: Notion{DatabaseID: "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", FilterProp: "Status", FilterValue: []string{"Ready"}, PublishedValue: "Published"}},
+		{Name: "typo", Notion: Notion{DatabaseID: "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", FilterProp: "Status", FilterValue: []string{"Done"},
+			WriteBack: WriteBack{SlugProp: "Slug"}}},
+		{Name: "private", Notion: Notion{DatabaseID: "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"}},
+	}
+	assert.Equal(t, []string{
+		`✔ : NOTION_SECRET is valid, the integration is "Blog"`,
+		`✔ blog: the integration can access the database "Posts"`,
+		"⚠ blog: the title property is Title, not Name",
+		"✔ blog: the filterProp Status is a status property",
+		"✔ blog: the filterValue and the publishedValue are options of Status",
+		"? blog: not verified: the status and the write-back require the Update content capability, which can't be checked without writing to Notion",
+		`✔ typo: the integration can access the database "Posts"`,
+		"⚠ typo: the title property is Title, not Name",
+		"✔ typo: the filterProp Status is a status property",
+		`✘ typo: "Done" isn't an option of Status`,
+		"✘ typo: write-back: property Slug not found in the database",
+		"? typo: not verified: the status and the write-back require the Update content capability, which can't be checked without writing to Notion",
+		"✘ private: the integration can't access the database bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+	}, statuses(diagnose(fetcher, "secret", jobs)))
+}
@@ -0,0 +1,194 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bonaysoft/notion-md-gen/pkg/tomarkdown"
+
+	"github.com/dstotijn/go-notion"
+)
+
+// document is a page exported as a markdown file, along with its nested child pages and child databases.
+type document struct {
+	page     notion.Page
+	title    string
+	filename string // relative to the PostSavePath, always slash separated
+	dir      string // directory of the nested documents, relative to the PostSavePath
+	group    string // directory of the child database which the document belongs to
+	position int    // 1-based position among the siblings, 0 for the top-level documents
+	assetKey string // prefix of the assets in the image storage
+
+	blocks    []notion.Block
+	children  []*document
+	databases map[string]tomarkdown.ChildDatabase
+	columns   map[string]float64 // the width ratios of the columns, shared by the document tree
+}
+
+func newDocument(page notion.Page, config Markdown) *document {
+	title := pageTitle(page)
+	filename := filepath.ToSlash(generateArticleFilename(config.PageNamePrefix+title, page.CreatedTime, config))
+	return &document{
+		page:     page,
+		title:    title,
+		filename: filename,
+		dir:      strings.TrimSuffix(filename, ".md"),
+		assetKey: config.PageNamePrefix + title,
+	}
+}
+
+func (d *document) addChild(page notion.Page, title, group string) *document {
+	child := &document{
+		page:     page,
+		title:    title,
+		group:    group,
+		position: len(d.children) + 1,
+		assetKey: path.Join(d.assetKey, group, title),
+		columns:  d.columns,
+	}
+	d.children = append(d.children, child)
+	return child
+}
+
+// layoutChildren assigns the filenames of the nested documents, which live in the directory of their parent.
+// If index is set, a document with children becomes the index file of its own directory.
+func (d *document) layoutChildren(index string) {
+	for _, child := range d.children {
+		name := escapeFilename(child.title)
+		child.dir = path.Join(d.dir, escapeFilename(child.group), name)
+		child.filename = child.dir + ".md"
+		if index != "" && len(child.children) > 0 {
+			child.filename = path.Join(child.dir, index)
+		}
+		child.layoutChildren(index)
+	}
+}
+
+// walk calls fn for the document and all its nested documents.
+func (d *document) walk(fn func(d *document)) {
+	fn(d)
+	for _, child := range d.children {
+		child.walk(fn)
+	}
+}
+
+// links maps the IDs of the document tree to their filenames.
+func (d *document) links() map[string]string {
+	links := make(map[string]string)
+	d.walk(func(d *document) {
+		if id, ok := tomarkdown.ParseNotionID(d.page.ID); ok {
+			links[id] = d.filename
+		}
+	})
+
+	return links
+}
+
+// fetchChildDocuments retrieves the child pages and the entries of the child databases recursively.
+func fetchChildDocuments(fetcher *blockFetcher, doc *document) error {
+	var walk func(blocks []notion.Block) error
+	walk = func(blocks []notion.Block) error {
+		for _, block := range blocks {
+			var err error
+			switch block.Type {
+			case notion.BlockTypeChildPage:
+				err = fetchChildPage(fetcher, doc, block)
+			case notion.BlockTypeChildDatabase:
+				err = fetchChildDatabase(fetcher, doc, block)
+			default:
+				err = walk(tomarkdown.ChildrenBlocks(block))
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return walk(doc.blocks)
+}
+
+func fetchChildPage(fetcher *blockFetcher, doc *document, block notion.Block) error {
+	page, err := fetcher.client.FindPageByID(context.Background(), block.ID)
+	if err != nil {
+		return fmt.Errorf("child page %s: %w", block.ChildPage.Title, err)
+	}
+
+	child := doc.addChild(page, block.ChildPage.Title, "")
+	if child.blocks, err = fetcher.retrieveBlockChildren(page.ID); err != nil {
+		return fmt.Errorf("child page %s: %w", child.title, err)
+	}
+	return fetchChildDocuments(fetcher, child)
+}
+
+func fetchChildDatabase(fetcher *blockFetcher, doc *document, block notion.Block) error {
+	title := block.ChildDatabase.Title
+	entries, err := queryDatabasePages(fetcher.client, block.ID, nil)
+	if err != nil {
+		return fmt.Errorf("child database %s: %w", title, err)
+	}
+
+	for _, entry := range entries {
+		child := doc.addChild(entry, pageTitle(entry), title)
+		if child.blocks, err = fetcher.retrieveBlockChildren(entry.ID); err != nil {
+			return fmt.Errorf("child database %s: %w", title, err)
+		}
+		if err := fetchChildDocuments(fetcher, child); err != nil {
+			return err
+		}
+	}
+
+	if doc.databases == nil {
+		doc.databases = make(map[string]tomarkdown.ChildDatabase)
+	}
+	id, _ := tomarkdown.ParseNotionID(block.ID)
+	doc.databases[id] = tomarkdown.NewChildDatabase(title, entries)
+	return nil
+}
+
+// relativeLinks converts the filenames of the links to the links relative to the document.
+func relativeLinks(from string, filenames map[string]string) map[string]string {
+	links := make(map[string]string, len(filenames))
+	for id, filename := range filenames {
+		links[id] = relativeLink(from, filename)
+	}
+
+	return links
+}
+
+func relativeLink(from, to string) string {
+	rel, err := filepath.Rel(filepath.Dir(filepath.FromSlash(from)), filepath.FromSlash(to))
+	if err != nil {
+		return to
+	}
+
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// pageTitle returns the title of a database page or a child page.
+func pageTitle(page notion.Page) string {
+	switch props := page.Properties.(type) {
+	case notion.DatabasePageProperties:
+		if name, ok := props["Name"]; ok {
+			return tomarkdown.ConvertRichText(name.Title)
+		}
+		for _, prop := range props {
+			if prop.Type == notion.DBPropTypeTitle {
+				return tomarkdown.ConvertRichText(prop.Title)
+			}
+		}
+	case notion.PageProperties:
+		return tomarkdown.ConvertRichText(props.Title.Title)
+	}
+
+	return ""
+}
@@ -0,0 +1,95 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dstotijn/go-notion"
+)
+
+// ErrorKind is the kind of a failure, the command exits with a distinct code for each kind.
+type ErrorKind string
+
+const (
+	ErrorConfig ErrorKind = "config" // invalid config, e.g. an unknown job or property
+	ErrorAuth   ErrorKind = "auth"   // invalid secret, or the integration isn't shared with the page
+	ErrorAPI    ErrorKind = "api"    // other failures of the Notion API
+	ErrorRender ErrorKind = "render" // failures of generating the markdown files
+)
+
+// severity orders the kinds, the exit code of a run is the one of its most severe error.
+var severity = map[ErrorKind]int{ErrorRender: 1, ErrorAPI: 2, ErrorAuth: 3, ErrorConfig: 4}
+
+// Error is a failure of a job, or of a page of the job.
+type Error struct {
+	Kind ErrorKind
+	Job  string
+	Page string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s]", e.Kind)
+	if e.Job != "" {
+		fmt.Fprintf(&b, " job %s", e.Job)
+	}
+	if e.Page != "" {
+		fmt.Fprintf(&b, " page %s", e.Page)
+	}
+	fmt.Fprintf(&b, ": %v", e.Err)
+	return b.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errors is the failures collected by a run.
+type Errors []*Error
+
+func (errs Errors) Error() string {
+	lines := make([]string, 0, len(errs)+1)
+	lines = append(lines, fmt.Sprintf("%d error(s) occurred:", len(errs)))
+	for _, err := range errs {
+		lines = append(lines, "  "+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Kind returns the most severe kind of the errors.
+func (errs Errors) Kind() ErrorKind {
+	var kind ErrorKind
+	for _, err := range errs {
+		if severity[err.Kind] > severity[kind] {
+			kind = err.Kind
+		}
+	}
+	return kind
+}
+
+func configError(err error) *Error {
+	return &Error{Kind: ErrorConfig, Err: err}
+}
+
+func renderError(err error) *Error {
+	return &Error{Kind: ErrorRender, Err: err}
+}
+
+// apiError classifies the error of the Notion API, the invalid secret and the missing permission are auth errors.
+func apiError(err error) *Error {
+	if errors.Is(err, notion.ErrUnauthorized) || errors.Is(err, notion.ErrRestrictedResource) {
+		return &Error{Kind: ErrorAuth, Err: err}
+	}
+	return &Error{Kind: ErrorAPI, Err: err}
+}
+
+// resourceError classifies the error of the configured database or root page like apiError,
+// except that a missing one is an auth error, since it's usually not shared with the integration.
+func resourceError(err error) *Error {
+	if errors.Is(err, notion.ErrObjectNotFound) {
+		return &Error{Kind: ErrorAuth, Err: err}
+	}
+	return apiError(err)
+}
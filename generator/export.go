@@ -0,0 +1,82 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/bonaysoft/notion-md-gen/pkg/logger"
+	"github.com/bonaysoft/notion-md-gen/pkg/tomarkdown"
+)
+
+// Export renders a single page by its ID or URL with the settings of the first selected job, e.g. for debugging.
+// The page is written to the file, to the stdout if the file is "-", or like a sync into the PostSavePath if it's empty.
+// The nested documents aren't exported and nothing is written back to Notion.
+func Export(config Config, opts Options, page, filename string) error {
+	jobs, err := config.SelectJobs(opts.Jobs...)
+	if err != nil {
+		return Errors{configError(err)}
+	}
+	pageID, ok := tomarkdown.ParseNotionID(page)
+	if !ok {
+		return Errors{configError(fmt.Errorf("invalid Notion page ID or URL: %s", page))}
+	}
+
+	job := jobs[0]
+	out := &output{}
+	if err := exportPage(newBlockFetcher(os.Getenv("NOTION_SECRET"), newHTTPClient()), job, pageID, filename, os.Stdout, out); err != nil {
+		err.Job, err.Page = job.Name, pageID
+		return Errors{err}
+	}
+	return nil
+}
+
+func exportPage(fetcher *blockFetcher, config Job, pageID, filename string, stdout io.Writer, out *output) *Error {
+	store, err := prepareStorage(config.Markdown, out)
+	if err != nil {
+		return err
+	}
+	doc, err := fetchDocument(fetcher, config.Markdown, pageID)
+	if err != nil {
+		return err
+	}
+
+	content, e := render(doc, nil, config.Markdown, store, nil)
+	if e != nil {
+		return renderError(fmt.Errorf("error generating blog post: %v", e))
+	}
+	switch filename {
+	case "-":
+		_, e = stdout.Write(content)
+	case "":
+		filename = filepath.Join(config.Markdown.PostSavePath, filepath.FromSlash(doc.filename))
+		fallthrough
+	default:
+		e = out.writeFile(filename, content)
+	}
+	if e != nil {
+		return renderError(fmt.Errorf("error writing %s: %s", filename, e))
+	}
+	logger.Info("✔ Exporting page: Completed", "page", doc.title, "file", filename)
+
+	return nil
+}
+
+// fetchDocument fetches the page and its blocks tree, without the nested documents.
+func fetchDocument(fetcher *blockFetcher, config Markdown, pageID string) (*document, *Error) {
+	page, err := fetcher.client.FindPageByID(context.Background(), pageID)
+	if err != nil {
+		return nil, apiError(fmt.Errorf("❌ Finding Notion page: %w", err))
+	}
+
+	doc := newDocument(page, config)
+	doc.columns = fetcher.columns
+	if doc.blocks, err = queryBlockChildren(fetcher, page.ID); err != nil {
+		return doc, apiError(fmt.Errorf("error getting blocks: %w", err))
+	}
+	logger.Info("✔ Getting blocks tree: Completed", "blocks", len(doc.blocks))
+
+	return doc, nil
+}
@@ -0,0 +1,327 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dstotijn/go-notion"
+	"gopkg.in/yaml.v3"
+)
+
+// Filter is a declarative database filter in the config, it follows the shape of the Notion API:
+//
+//	filter:
+//	  and:
+//	    - property: Status
+//	      select: { equals: Published }
+//	    - property: Date
+//	      date: { on_or_before: today }
+//
+// See: https://developers.notion.com/reference/post-database-query-filter
+type Filter struct {
+	node *yaml.Node
+}
+
+// Sort is a sort of the database query, by a property or by a timestamp.
+type Sort struct {
+	Property  string `yaml:"property,omitempty"`
+	Timestamp string `yaml:"timestamp,omitempty"` // created_time,last_edited_time
+	Direction string `yaml:"direction,omitempty"` // ascending,descending
+}
+
+type valueKind int
+
+type filterType struct {
+	key       string
+	operators map[string]valueKind
+}
+
+const (
+	kindString valueKind = iota
+	kindNumber
+	kindBool
+	kindDate
+	kindTrue  // only true is allowed, e.g. is_empty
+	kindEmpty // an empty object, e.g. past_week
+)
+
+var (
+	textOperators = map[string]valueKind{
+		"equals": kindString, "does_not_equal": kindString, "contains": kindString, "does_not_contain": kindString,
+		"starts_with": kindString, "ends_with": kindString, "is_empty": kindTrue, "is_not_empty": kindTrue,
+	}
+	listOperators = map[string]valueKind{
+		"contains": kindString, "does_not_contain": kindString, "is_empty": kindTrue, "is_not_empty": kindTrue,
+	}
+	selectOperators = map[string]valueKind{
+		"equals": kindString, "does_not_equal": kindString, "is_empty": kindTrue, "is_not_empty": kindTrue,
+	}
+	dateOperators = map[string]valueKind{
+		"equals": kindDate, "before": kindDate, "after": kindDate, "on_or_before": kindDate, "on_or_after": kindDate,
+		"is_empty": kindTrue, "is_not_empty": kindTrue,
+		"past_week": kindEmpty, "past_month": kindEmpty, "past_year": kindEmpty,
+		"next_week": kindEmpty, "next_month": kindEmpty, "next_year": kindEmpty,
+	}
+
+	// filterTypes maps the property types to the filter keys of the API and their operators.
+	filterTypes = map[string]filterType{
+		"text":         {"text", textOperators},
+		"title":        {"text", textOperators},
+		"rich_text":    {"text", textOperators},
+		"url":          {"text", textOperators},
+		"email":        {"text", textOperators},
+		"phone_number": {"text", textOperators},
+		"number": {"number", map[string]valueKind{
+			"equals": kindNumber, "does_not_equal": kindNumber, "greater_than": kindNumber, "less_than": kindNumber,
+			"greater_than_or_equal_to": kindNumber, "less_than_or_equal_to": kindNumber, "is_empty": kindTrue, "is_not_empty": kindTrue,
+		}},
+		"checkbox":         {"checkbox", map[string]valueKind{"equals": kindBool, "does_not_equal": kindBool}},
+		"select":           {"select", selectOperators},
+		"status":           {"status", selectOperators},
+		"multi_select":     {"multi_select", listOperators},
+		"date":             {"date", dateOperators},
+		"created_time":     {"date", dateOperators},
+		"last_edited_time": {"date", dateOperators},
+		"people":           {"people", listOperators},
+		"created_by":       {"people", listOperators},
+		"last_edited_by":   {"people", listOperators},
+		"files":            {"files", map[string]valueKind{"is_empty": kindTrue, "is_not_empty": kindTrue}},
+		"relation":         {"relation", listOperators},
+	}
+)
+
+func (f *Filter) UnmarshalYAML(node *yaml.Node) error {
+	if _, err := buildFilter(node, time.Now()); err != nil {
+		return err
+	}
+
+	f.node = node
+	return nil
+}
+
+func (f Filter) MarshalYAML() (interface{}, error) {
+	return f.node, nil
+}
+
+// Build returns the filter of the database query in the JSON form of the API,
+// the relative dates like today are resolved against now.
+func (f *Filter) Build(now time.Time) (map[string]interface{}, error) {
+	if f == nil || f.node == nil {
+		return nil, nil
+	}
+
+	return buildFilter(f.node, now)
+}
+
+func (s *Sort) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return nodeError(node, "sort must be a mapping")
+	}
+	for key, value := range mappingFields(node) {
+		if value.Kind != yaml.ScalarNode {
+			return nodeError(value, "%s must be a string", key)
+		}
+		switch key {
+		case "property":
+			s.Property = value.Value
+		case "timestamp":
+			s.Timestamp = value.Value
+		case "direction":
+			s.Direction = value.Value
+		default:
+			return nodeError(keyNode(node, key), "unknown sort key %q (want property, timestamp or direction)", key)
+		}
+	}
+
+	switch {
+	case s.Property == "" && s.Timestamp == "":
+		return nodeError(node, "sort requires a property or a timestamp")
+	case s.Property != "" && s.Timestamp != "":
+		return nodeError(node, "sort requires either a property or a timestamp, not both")
+	case s.Timestamp != "" && s.Timestamp != string(notion.SortTimeStampCreatedTime) && s.Timestamp != string(notion.SortTimeStampLastEditedTime):
+		return nodeError(node, "unknown sort timestamp %q (want created_time or last_edited_time)", s.Timestamp)
+	case s.Direction != "" && s.Direction != string(notion.SortDirAsc) && s.Direction != string(notion.SortDirDesc):
+		return nodeError(node, "unknown sort direction %q (want ascending or descending)", s.Direction)
+	}
+
+	return nil
+}
+
+func buildSorts(sorts []Sort) []notion.DatabaseQuerySort {
+	result := make([]notion.DatabaseQuerySort, 0, len(sorts))
+	for _, s := range sorts {
+		result = append(result, notion.DatabaseQuerySort{
+			Property:  s.Property,
+			Timestamp: notion.SortTimestamp(s.Timestamp),
+			Direction: notion.SortDirection(s.Direction),
+		})
+	}
+
+	return result
+}
+
+// buildFilter validates the filter node and converts it to the JSON form of the API.
+func buildFilter(node *yaml.Node, now time.Time) (map[string]interface{}, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, nodeError(node, "filter must be a mapping")
+	}
+
+	fields := mappingFields(node)
+	for _, compound := range []string{"and", "or"} {
+		sub, ok := fields[compound]
+		if !ok {
+			continue
+		}
+		if len(fields) != 1 {
+			return nil, nodeError(node, "%q can't be mixed with other keys", compound)
+		}
+		if sub.Kind != yaml.SequenceNode || len(sub.Content) == 0 {
+			return nil, nodeError(sub, "%q must be a non-empty list of filters", compound)
+		}
+
+		filters := make([]interface{}, 0, len(sub.Content))
+		for _, item := range sub.Content {
+			filter, err := buildFilter(item, now)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, filter)
+		}
+		return map[string]interface{}{compound: filters}, nil
+	}
+
+	property, ok := fields["property"]
+	if !ok || property.Kind != yaml.ScalarNode || property.Value == "" {
+		return nil, nodeError(node, "filter requires a property, or a list of filters by and/or")
+	}
+	if len(fields) != 2 {
+		return nil, nodeError(node, "filter of property %q requires exactly one condition, e.g. select: { equals: Published }", property.Value)
+	}
+
+	for typ, cond := range fields {
+		if typ == "property" {
+			continue
+		}
+
+		ft, ok := filterTypes[typ]
+		if !ok {
+			return nil, nodeError(keyNode(node, typ), "unknown property type %q (want one of %s)", typ, strings.Join(sortedKeys(filterTypes), ", "))
+		}
+		condition, err := buildCondition(typ, cond, ft.operators, now)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"property": property.Value, ft.key: condition}, nil
+	}
+
+	return nil, nil
+}
+
+func buildCondition(typ string, node *yaml.Node, operators map[string]valueKind, now time.Time) (map[string]interface{}, error) {
+	if node.Kind != yaml.MappingNode || len(node.Content) != 2 {
+		return nil, nodeError(node, "%s condition requires exactly one operator, e.g. { equals: value }", typ)
+	}
+
+	op, value := node.Content[0], node.Content[1]
+	kind, ok := operators[op.Value]
+	if !ok {
+		names := make([]string, 0, len(operators))
+		for name := range operators {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, nodeError(op, "unknown %s operator %q (want one of %s)", typ, op.Value, strings.Join(names, ", "))
+	}
+
+	var v interface{}
+	var err error
+	switch kind {
+	case kindString:
+		if value.Kind != yaml.ScalarNode {
+			return nil, nodeError(value, "%s requires a string", op.Value)
+		}
+		v = value.Value
+	case kindNumber:
+		var f float64
+		err = value.Decode(&f)
+		v = f
+	case kindBool:
+		var b bool
+		err = value.Decode(&b)
+		v = b
+	case kindTrue:
+		var b bool
+		if err = value.Decode(&b); err == nil && !b {
+			return nil, nodeError(value, "%s only accepts true", op.Value)
+		}
+		v = true
+	case kindEmpty:
+		if value.Tag != "!!null" && (value.Kind != yaml.MappingNode || len(value.Content) != 0) {
+			return nil, nodeError(value, "%s requires an empty value, e.g. %s: {}", op.Value, op.Value)
+		}
+		v = struct{}{}
+	case kindDate:
+		v, err = parseDate(value.Value, now)
+	}
+	if err != nil {
+		return nil, nodeError(value, "invalid value of %s: %s", op.Value, err)
+	}
+
+	return map[string]interface{}{op.Value: v}, nil
+}
+
+// parseDate parses a date of the filter, the relative dates today, yesterday, tomorrow and now are supported.
+func parseDate(s string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch s {
+	case "now":
+		return now, nil
+	case "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date like 2006-01-02, 2006-01-02T15:04:05Z07:00, today or now", s)
+}
+
+func mappingFields(node *yaml.Node) map[string]*yaml.Node {
+	fields := make(map[string]*yaml.Node, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		fields[node.Content[i].Value] = node.Content[i+1]
+	}
+
+	return fields
+}
+
+func keyNode(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i]
+		}
+	}
+
+	return node
+}
+
+func sortedKeys(m map[string]filterType) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func nodeError(node *yaml.Node, format string, args ...interface{}) error {
+	return fmt.Errorf("line %d, column %d: %s", node.Line, node.Column, fmt.Sprintf(format, args...))
+}
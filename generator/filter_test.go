@@ -0,0 +1,61 @@
+package generator
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/dstotijn/go-notion"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestFilter(t *testing.T) {
+	var config Notion
+	err := yaml.Unmarshal([]byte(`
+filter:
+  and:
+    - property: Status
+      select: { equals: Published }
+    - property: Tags
+      multi_select: { contains: go }
+    - property: Date
+      date: { on_or_before: today }
+sorts:
+  - property: Date
+    direction: descending
+  - timestamp: last_edited_time
+`), &config)
+	assert.NoError(t, err)
+
+	now := time.Date(2022, 1, 25, 6, 46, 0, 0, time.UTC)
+	filter, err := config.Filter.Build(now)
+	assert.NoError(t, err)
+	b, _ := json.Marshal(filter)
+	assert.JSONEq(t, `{"and": [
+		{"property": "Status", "select": {"equals": "Published"}},
+		{"property": "Tags", "multi_select": {"contains": "go"}},
+		{"property": "Date", "date": {"on_or_before": "2022-01-25T00:00:00Z"}}
+	]}`, string(b))
+	assert.Equal(t, []notion.DatabaseQuerySort{
+		{Property: "Date", Direction: notion.SortDirDesc},
+		{Timestamp: notion.SortTimeStampLastEditedTime},
+	}, buildSorts(config.Sorts))
+}
+
+func TestFilterError(t *testing.T) {
+	tests := map[string]string{
+		"filter:\n  property: Status\n  select: { is: Published }\n":          "line 3, column 13: unknown select operator \"is\"",
+		"filter:\n  property: Status\n  state: { equals: Published }\n":       "line 3, column 3: unknown property type \"state\"",
+		"filter:\n  or:\n    - property: Date\n      date: { after: soon }\n": "line 4, column 22: invalid value of after",
+		"sorts:\n  - property: Date\n    direction: down\n":                   "line 2, column 5: unknown sort direction \"down\"",
+		"filter:\n  property: Stars\n  number: { equals: many }\n":            "line 3, column 21: invalid value of equals",
+	}
+	for data, want := range tests {
+		var config Notion
+		err := yaml.Unmarshal([]byte(data), &config)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), want)
+		}
+	}
+}
@@ -1,90 +1,426 @@
 package generator
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"net/url"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/bonaysoft/notion-md-gen/pkg/logger"
+	"github.com/bonaysoft/notion-md-gen/pkg/storage"
 	"github.com/bonaysoft/notion-md-gen/pkg/tomarkdown"
 	"github.com/hashicorp/go-retryablehttp"
 
 	"github.com/dstotijn/go-notion"
 )
 
-func Run(config Config) error {
-	if err := os.MkdirAll(config.Markdown.PostSavePath, 0755); err != nil {
-		return fmt.Errorf("couldn't create content folder: %s", err)
+// Options are the options of a run.
+type Options struct {
+	Jobs     []string // the names of the jobs to run, all the jobs if empty
+	DryRun   bool     // render in memory and print the changes, without writing files or updating Notion
+	Diff     bool     // print the unified diffs of the changed files in a dry run
+	FailFast bool     // stop at the first failing page, instead of continuing with the remaining pages
+	Report   string   // the file to write the JSON report of the run to
+	Page     string   // the ID or URL of the single page to sync, it's synced by the jobs of its database regardless of the filter
+}
+
+// Run runs the sync jobs selected by the options.
+// The jobs share the Notion client, so the requests of all jobs are rate limited together.
+// The failures are collected and returned as Errors.
+func Run(config Config, opts Options) error {
+	_, err := run(config, opts)
+	return err
+}
+
+// run is Run returning the report of the run as well.
+func run(config Config, opts Options) (*Report, error) {
+	startedAt := time.Now()
+	out := &output{dryRun: opts.DryRun, diff: opts.Diff}
+	errs := runJobs(config, opts, out)
+	if !opts.DryRun {
+		errs = append(errs, runSyncHooks(config, opts, out)...)
+	}
+
+	report := newReport(startedAt, out, errs)
+	if err := writeReport(report, opts.Report); err != nil {
+		errs = append(errs, renderError(err))
+	}
+	if config.Hooks.Post != "" && !opts.DryRun {
+		if err := runReportHook(config.Hooks.Post, report); err != nil {
+			errs = append(errs, renderError(err))
+		}
+	}
+	if len(errs) > 0 {
+		return report, errs
+	}
+	return report, nil
+}
+
+// runSyncHooks runs the file hooks and commits the generated files, after the jobs.
+func runSyncHooks(config Config, opts Options, out *output) Errors {
+	var errs Errors
+	if config.Hooks.File != "" {
+		errs = runFileHooks(config.Hooks.File, out.pages)
+	}
+	if config.Git.Commit {
+		jobs, _ := config.SelectJobs(opts.Jobs...)
+		if err := commitFiles(config.Git, jobs, out.pages); err != nil {
+			errs = append(errs, renderError(fmt.Errorf("error committing files: %s", err)))
+		}
+	}
+
+	return errs
+}
+
+func runJobs(config Config, opts Options, out *output) Errors {
+	jobs, err := config.SelectJobs(opts.Jobs...)
+	if err != nil {
+		return Errors{configError(err)}
+	}
+
+	fetcher := newBlockFetcher(os.Getenv("NOTION_SECRET"), newHTTPClient())
+	var page *notion.Page
+	if opts.Page != "" {
+		var e *Error
+		if page, e = findPage(fetcher, opts.Page); e != nil {
+			return Errors{e}
+		}
+	}
+
+	dirs := make([]string, 0, len(jobs))
+	var errs Errors
+	for _, job := range jobs {
+		if page != nil && !inDatabase(*page, job.Notion.DatabaseID) {
+			continue
+		}
+		if len(jobs) > 1 {
+			logger.Info("== Job "+job.Name+" ==", "job", job.Name)
+		}
+
+		var jobErrs Errors
+		if page != nil {
+			jobErrs = runPage(fetcher, job, *page, out)
+		} else {
+			jobErrs = runJob(fetcher, job, out, opts.FailFast)
+		}
+		for _, err := range jobErrs {
+			err.Job = job.Name
+			errs = append(errs, err)
+		}
+		if len(errs) > 0 && opts.FailFast {
+			return errs
+		}
+		dirs = append(dirs, job.Markdown.PostSavePath)
+	}
+	if page != nil && len(dirs) == 0 {
+		return Errors{configError(fmt.Errorf("page %s isn't in the database of any selected job", opts.Page))}
+	}
+
+	if opts.DryRun && page == nil {
+		since := len(out.changes)
+		if err := out.findStale(dirs...); err != nil {
+			return append(errs, renderError(err))
+		}
+		out.printChanges(since)
+		out.printSummary()
 	}
+	return errs
+}
+
+func newHTTPClient() *http.Client {
+	retryClient := retryablehttp.NewClient()
+	retryClient.Logger = logger.Default()
+	retryClient.HTTPClient.Transport = newRateLimiter(newAPILogger(retryClient.HTTPClient.Transport), notionRequestInterval)
+	return retryClient.StandardClient()
+}
+
+func runJob(fetcher *blockFetcher, config Job, out *output, failFast bool) Errors {
+	store, err := prepareStorage(config.Markdown, out)
+	if err != nil {
+		return Errors{err}
+	}
+
+	if config.Notion.RootPageID != "" {
+		recorder := newPageRecorder(fetcher, store, out)
+		title, err := runPageTree(fetcher, config, recorder.assets, out)
+		recorder.done(fetcher, out, PageReport{Job: config.Name, ID: config.Notion.RootPageID, Title: title}, err)
+		if err != nil {
+			return Errors{err}
+		}
+		return nil
+	}
+
+	job, err := prepareDatabaseJob(fetcher, config, store)
+	if err != nil {
+		return Errors{err}
+	}
+	job.publish = !out.dryRun
 
 	// find database page
-	client := notion.NewClient(os.Getenv("NOTION_SECRET"), notion.WithHTTPClient(retryablehttp.NewClient().StandardClient()))
-	q, err := queryDatabase(client, config.Notion)
+	pages, err := job.query(fetcher)
+	if err != nil {
+		return Errors{err}
+	}
+
+	return job.syncPages(fetcher, pages, out, failFast)
+}
+
+// runPage syncs the single page of the job's database.
+func runPage(fetcher *blockFetcher, config Job, page notion.Page, out *output) Errors {
+	store, err := prepareStorage(config.Markdown, out)
+	if err != nil {
+		return Errors{err}
+	}
+	job, err := prepareDatabaseJob(fetcher, config, store)
+	if err != nil {
+		return Errors{err}
+	}
+	job.publish = !out.dryRun
+	// the page synced on demand, e.g. a draft to preview, is published only if the filterValue selects it
+	if job.publish && job.status != nil && len(config.Notion.FilterValue) > 0 {
+		selected, err := job.status.selected(fetcher, page, config.Notion.FilterValue)
+		if err != nil {
+			return Errors{apiError(fmt.Errorf("❌ Checking the status of the page: %w", err))}
+		}
+		job.publish = selected
+	}
+
+	return job.syncPages(fetcher, []notion.Page{page}, out, false)
+}
+
+// findPage retrieves the page by its ID or URL.
+func findPage(fetcher *blockFetcher, s string) (*notion.Page, *Error) {
+	id, ok := tomarkdown.ParseNotionID(s)
+	if !ok {
+		return nil, configError(fmt.Errorf("invalid Notion page ID or URL: %s", s))
+	}
+	page, err := fetcher.client.FindPageByID(context.Background(), id)
+	if err != nil {
+		return nil, apiError(fmt.Errorf("❌ Finding Notion page: %w", err))
+	}
+
+	return &page, nil
+}
+
+// inDatabase returns true if the page is an entry of the database.
+func inDatabase(page notion.Page, databaseID string) bool {
+	if page.Parent.Type != notion.ParentTypeDatabase {
+		return false
+	}
+	parentID, _ := tomarkdown.ParseNotionID(page.Parent.DatabaseID)
+	id, ok := tomarkdown.ParseNotionID(databaseID)
+	return ok && parentID == id
+}
+
+// prepareStorage returns the image storage, and creates the content folder unless it's a dry run.
+func prepareStorage(config Markdown, out *output) (storage.Storage, *Error) {
+	store, err := newStorage(config)
 	if err != nil {
-		return fmt.Errorf("❌ Querying Notion database: %s", err)
+		return nil, configError(fmt.Errorf("couldn't create image storage: %s", err))
+	}
+	if out.dryRun {
+		return storage.Discard(store), nil
+	}
+	if err := os.MkdirAll(config.PostSavePath, 0755); err != nil {
+		return nil, renderError(fmt.Errorf("couldn't create content folder: %s", err))
 	}
-	fmt.Println("✔ Querying Notion database: Completed")
 
-	// fetch page children
-	changed := 0 // number of article status changed
-	for i, page := range q.Results {
-		pageName := tomarkdown.ConvertRichText(page.Properties.(notion.DatabasePageProperties)["Name"].Title)
-		fmt.Printf("-- Article [%d/%d] %s --\n", i+1, len(q.Results), pageName)
+	return store, nil
+}
+
+// databaseJob is a job syncing the pages of a database, the state is shared by its pages.
+type databaseJob struct {
+	config  Job
+	store   storage.Storage
+	status  *statusProperty
+	wb      *writeBack
+	trail   []tomarkdown.Breadcrumb
+	publish bool // write the status and the write-back properties to Notion after the sync
+}
 
-		// Get page blocks tree
-		blocks, err := queryBlockChildren(client, page.ID)
+func prepareDatabaseJob(fetcher *blockFetcher, config Job, store storage.Storage) (*databaseJob, *Error) {
+	// The schema is required by the status, the write-back and the breadcrumb trail
+	var db notion.Database
+	var err error
+	if config.Notion.FilterProp != "" || config.Notion.WriteBack != (WriteBack{}) || config.Markdown.Breadcrumb == "trail" {
+		db, err = fetcher.client.FindDatabaseByID(context.Background(), config.Notion.DatabaseID)
 		if err != nil {
-			return fmt.Errorf("error getting blocks: %v", err)
+			return nil, resourceError(fmt.Errorf("❌ Finding Notion database: %w", err))
 		}
-		fmt.Println("✔ Getting blocks tree: Completed")
+	}
+
+	job := &databaseJob{config: config, store: store}
+	job.status, err = findStatusProperty(db, config.Notion)
+	if err != nil {
+		return nil, configError(fmt.Errorf("❌ Finding status property: %s", err))
+	}
+	job.wb, err = newWriteBack(db, config)
+	if err != nil {
+		return nil, configError(fmt.Errorf("❌ Finding write-back properties: %s", err))
+	}
+	if config.Markdown.Breadcrumb == "trail" {
+		job.trail = append(job.trail, tomarkdown.Breadcrumb{Title: tomarkdown.ConvertPlainText(db.Title)})
+	}
+
+	return job, nil
+}
 
-		// Generate content to file
-		if err := generate(page, blocks, config.Markdown); err != nil {
-			return fmt.Errorf("error generating blog post: %v", err)
+// query returns the pages of the database selected by the filter.
+func (j *databaseJob) query(fetcher *blockFetcher) ([]notion.Page, *Error) {
+	q, err := queryDatabase(fetcher, j.config.Notion, j.status)
+	if err != nil {
+		return nil, resourceError(fmt.Errorf("❌ Querying Notion database: %w", err))
+	}
+	logger.Info("✔ Querying Notion database: Completed", "pages", len(q.Results))
+
+	return q.Results, nil
+}
+
+// syncPages generates the pages, the failing pages are collected and the remaining pages continued unless failFast.
+func (j *databaseJob) syncPages(fetcher *blockFetcher, pages []notion.Page, out *output, failFast bool) Errors {
+	var errs Errors
+	for i, page := range pages {
+		doc := newDocument(page, j.config.Markdown)
+		logger.Info(fmt.Sprintf("-- Article [%d/%d] %s --", i+1, len(pages), doc.title), "id", page.ID)
+
+		recorder := newPageRecorder(fetcher, j.store, out)
+		err := syncPage(fetcher, doc, j.config.Markdown, recorder.assets, j.trail, out)
+		if out.dryRun {
+			out.printChanges(recorder.changes)
+		}
+		if err != nil {
+			logger.Error("❌ "+err.Err.Error(), "page", doc.title, "kind", err.Kind)
+			if j.publish {
+				j.wb.failed(fetcher, page, err.Err)
+			}
+			errs = append(errs, err)
+		} else if j.publish {
+			j.wb.published(fetcher, doc, time.Now())
 		}
-		fmt.Println("✔ Generating blog post: Completed")
 
 		// Change status of blog post if desired
-		if changeStatus(client, page, config.Notion) {
-			changed++
+		statusChanged := err == nil && j.publish && changeStatus(fetcher, page, j.status, j.config.Notion)
+		recorder.done(fetcher, out, PageReport{Job: j.config.Name, ID: page.ID, Title: doc.title, StatusChanged: statusChanged}, err)
+
+		if err != nil && failFast {
+			return errs
 		}
 	}
 
+	return errs
+}
+
+// syncPage fetches the blocks tree of the document and generates it.
+func syncPage(fetcher *blockFetcher, doc *document, config Markdown, store storage.Storage, trail []tomarkdown.Breadcrumb, out *output) *Error {
+	// Get page blocks tree
+	var err error
+	doc.blocks, err = queryBlockChildren(fetcher, doc.page.ID)
+	if err != nil {
+		return apiError(fmt.Errorf("error getting blocks: %w", err))
+	}
+	doc.columns = fetcher.columns
+	if config.ChildPages {
+		if err := fetchChildDocuments(fetcher, doc); err != nil {
+			return apiError(fmt.Errorf("error getting child pages: %w", err))
+		}
+		doc.layoutChildren("")
+	}
+	logger.Info("✔ Getting blocks tree: Completed", "blocks", len(doc.blocks))
+
+	// Generate content to file
+	if err := generate(doc, doc.links(), config, store, trail, out); err != nil {
+		return renderError(fmt.Errorf("error generating blog post: %v", err))
+	}
+	logger.Info("✔ Generating blog post: Completed", "file", doc.filename)
+
 	return nil
 }
 
-func generate(page notion.Page, blocks []notion.Block, config Markdown) error {
-	// Create file
-	pageName := config.PageNamePrefix + tomarkdown.ConvertRichText(page.Properties.(notion.DatabasePageProperties)["Name"].Title)
-	f, err := os.Create(filepath.Join(config.PostSavePath, generateArticleFilename(pageName, page.CreatedTime, config)))
+func newStorage(config Markdown) (storage.Storage, error) {
+	switch config.ImageStorage {
+	case "", "local":
+		return storage.NewLocal(config.ImageSavePath, config.ImagePublicLink), nil
+	case "s3":
+		s3Config := config.S3
+		if s3Config.AccessKeyID == "" {
+			s3Config.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		}
+		if s3Config.SecretAccessKey == "" {
+			s3Config.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		}
+		return storage.NewS3(s3Config)
+	}
+
+	return nil, fmt.Errorf("unknown image storage: %s", config.ImageStorage)
+}
+
+// generate writes the document and its nested documents. The URLs of the trail are the filenames of the documents,
+// the trail of the nested documents goes through the document.
+func generate(doc *document, links map[string]string, config Markdown, store storage.Storage, trail []tomarkdown.Breadcrumb, out *output) error {
+	if trail != nil {
+		trail = append(trail[:len(trail):len(trail)], tomarkdown.Breadcrumb{Title: doc.title, URL: doc.filename})
+	}
+	content, err := render(doc, links, config, store, trail)
 	if err != nil {
-		return fmt.Errorf("error create file: %s", err)
+		return err
+	}
+	if err := out.writeFile(filepath.Join(config.PostSavePath, filepath.FromSlash(doc.filename)), content); err != nil {
+		return err
 	}
 
-	// Generate markdown content to the file
+	for _, child := range doc.children {
+		if err := generate(child, links, config, store, trail, out); err != nil {
+			return fmt.Errorf("%s: %v", child.title, err)
+		}
+	}
+
+	return nil
+}
+
+// render generates the markdown content of the document, without its nested documents.
+// The trail ends with the document itself.
+func render(doc *document, links map[string]string, config Markdown, store storage.Storage, trail []tomarkdown.Breadcrumb) ([]byte, error) {
 	tm := tomarkdown.New()
-	tm.ImgSavePath = filepath.Join(config.ImageSavePath, pageName)
-	tm.ImgVisitPath = filepath.Join(config.ImagePublicLink, url.PathEscape(pageName))
+	tm.Storage = storage.WithPrefix(store, doc.assetKey)
+	if trail != nil {
+		tm.Breadcrumbs = make([]tomarkdown.Breadcrumb, 0, len(trail))
+		for i, crumb := range trail {
+			if crumb.URL != "" && i != len(trail)-1 {
+				crumb.URL = relativeLink(doc.filename, crumb.URL)
+			} else {
+				crumb.URL = ""
+			}
+			tm.Breadcrumbs = append(tm.Breadcrumbs, crumb)
+		}
+	}
 	tm.ContentTemplate = config.Template
-	tm.WithFrontMatter(page)
+	tm.ColumnLayout = config.ColumnLayout
+	tm.Links = relativeLinks(doc.filename, links)
+	tm.ChildDatabases = doc.databases
+	tm.ChildDatabaseLayout = config.ChildDatabase
+	tm.ColumnRatios = doc.columns
+	tm.WithFrontMatter(doc.page)
+	if doc.position > 0 {
+		tm.FrontMatter[sidebarKey(config)] = doc.position
+	}
 	if config.ShortcodeSyntax != "" {
-		tm.EnableExtendedSyntax(config.ShortcodeSyntax)
+		tm.EnableExtendedSyntax(config.ShortcodeSyntax, config.targets...)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tm.GenerateTo(doc.blocks, buf); err != nil {
+		return nil, err
 	}
 
-	return tm.GenerateTo(blocks, f)
+	return buf.Bytes(), nil
 }
 
 func generateArticleFilename(title string, date time.Time, config Markdown) string {
-	escapedTitle := strings.ReplaceAll(
-		strings.ToValidUTF8(
-			strings.ToLower(title),
-			"",
-		),
-		" ", "-",
-	)
-	escapedFilename := escapedTitle + ".md"
+	escapedFilename := escapeFilename(title) + ".md"
 
 	if config.GroupByMonth {
 		return filepath.Join(date.Format("2006-01-02"), escapedFilename)
@@ -92,3 +428,13 @@ func generateArticleFilename(title string, date time.Time, config Markdown) stri
 
 	return escapedFilename
 }
+
+func escapeFilename(title string) string {
+	return strings.ReplaceAll(
+		strings.ToValidUTF8(
+			strings.ToLower(title),
+			"",
+		),
+		" ", "-",
+	)
+}
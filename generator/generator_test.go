@@ -0,0 +1,364 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bonaysoft/notion-md-gen/pkg/storage"
+	"github.com/bonaysoft/notion-md-gen/pkg/tomarkdown"
+	"github.com/dstotijn/go-notion"
+	"github.com/stretchr/testify/assert"
+)
+
+func richText(content string) []notion.RichText {
+	return []notion.RichText{{Type: notion.RichTextTypeText, PlainText: content, Text: &notion.Text{Content: content}}}
+}
+
+func databasePage(id, title string) notion.Page {
+	return notion.Page{ID: id, CreatedTime: time.Date(2022, 1, 25, 0, 0, 0, 0, time.UTC), Parent: notion.Parent{Type: notion.ParentTypeDatabase},
+		Properties: notion.DatabasePageProperties{
+			"Name": {Type: notion.DBPropTypeTitle, Title: richText(title)},
+			"Tags": {Type: notion.DBPropTypeMultiSelect, MultiSelect: []notion.SelectOptions{{Name: "go"}, {Name: "cli"}}},
+		},
+	}
+}
+
+func TestGenerateChildDocuments(t *testing.T) {
+	const (
+		rootID  = "00000000000000000000000000000001"
+		childID = "00000000-0000-0000-0000-000000000002"
+		dbID    = "00000000-0000-0000-0000-000000000003"
+		entryID = "00000000-0000-0000-0000-000000000004"
+	)
+	fetcher := newTestFetcher(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/blocks/" + rootID + "/children":
+			writeBlocks(w,
+				notion.Block{Object: "block", ID: childID, Type: notion.BlockTypeChildPage, ChildPage: &notion.ChildPage{Title: "Install Guide"}},
+				notion.Block{Object: "block", ID: dbID, Type: notion.BlockTypeChildDatabase, ChildDatabase: &notion.ChildDatabase{Title: "Recipes"}},
+			)
+		case "/v1/pages/" + childID:
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"object": "page", "id": childID, "parent": notion.Parent{Type: notion.ParentTypePage, PageID: rootID},
+				"properties": map[string]interface{}{"title": notion.PageTitle{Title: richText("Install Guide")}},
+			})
+		case "/v1/blocks/" + childID + "/children":
+			link := richText("back")
+			link[0].Text.Link = &notion.Link{URL: "/Root-" + rootID}
+			writeBlocks(w, notion.Block{Object: "block", Type: notion.BlockTypeParagraph, Paragraph: &notion.RichTextBlock{Text: link}})
+		case "/v1/databases/" + dbID + "/query":
+			_ = json.NewEncoder(w).Encode(notion.DatabaseQueryResponse{Results: []notion.Page{databasePage(entryID, "Pasta")}})
+		case "/v1/blocks/" + entryID + "/children":
+			writeBlocks(w)
+		default:
+			t.Errorf("unexpected request: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	config := Markdown{PostSavePath: t.TempDir(), ChildPages: true, ChildDatabase: "table"}
+	doc := newDocument(databasePage(rootID, "Root"), config)
+	var err error
+	doc.blocks, err = fetcher.retrieveBlockChildren(rootID)
+	assert.NoError(t, err)
+	assert.NoError(t, fetchChildDocuments(fetcher, doc))
+	doc.layoutChildren("")
+
+	trail := []tomarkdown.Breadcrumb{{Title: "Docs"}}
+	assert.NoError(t, generate(doc, doc.links(), config, storage.NewLocal(t.TempDir(), "/images"), trail, &output{}))
+
+	read := func(name string) string {
+		content, err := ioutil.ReadFile(filepath.Join(config.PostSavePath, name))
+		assert.NoError(t, err)
+		return string(content)
+	}
+	root := read("root.md")
+	assert.Contains(t, root, "[Install Guide](root/install-guide.md)\n")
+	assert.Contains(t, root, "| Name | Tags |\n| :-- | :-- |\n| [Pasta](root/recipes/pasta.md) | go, cli |\n")
+	assert.Equal(t, "---\nsidebar_position: 1\ntitle: Install Guide\n---\n\n[back](../root.md)\n", read("root/install-guide.md"))
+	assert.Contains(t, read("root/recipes/pasta.md"), "tags:\n    - go\n    - cli\n")
+}
+
+func TestGenerateBreadcrumbs(t *testing.T) {
+	breadcrumb := []notion.Block{{Object: "block", Type: notion.BlockTypeBreadCrumb, Breadcrumb: &notion.Breadcrumb{}}}
+	config := Markdown{PostSavePath: t.TempDir()}
+	guide := newDocument(databasePage("guide", "Guide"), config)
+	step := guide.addChild(databasePage("step", "Step"), "Step", "")
+	detail := step.addChild(databasePage("detail", "Detail"), "Detail", "")
+	guide.blocks, step.blocks, detail.blocks = breadcrumb, breadcrumb, breadcrumb
+	guide.layoutChildren("")
+
+	trail := []tomarkdown.Breadcrumb{{Title: "Docs"}}
+	assert.NoError(t, generate(guide, guide.links(), config, storage.NewLocal(t.TempDir(), "/images"), trail, &output{}))
+
+	read := func(name string) string {
+		content, err := ioutil.ReadFile(filepath.Join(config.PostSavePath, name))
+		assert.NoError(t, err)
+		_, body := splitFrontMatter(content)
+		return string(body)
+	}
+	assert.Equal(t, "\nDocs / Guide\n", read("guide.md"))
+	assert.Equal(t, "\nDocs / [Guide](../guide.md) / Step\n", read("guide/step.md"))
+	assert.Equal(t, "\nDocs / [Guide](../../guide.md) / [Step](../step.md) / Detail\n", read("guide/step/detail.md"))
+}
+
+func TestRunPageTree(t *testing.T) {
+	page := func(id, parentID, title string) map[string]interface{} {
+		return map[string]interface{}{
+			"object": "page", "id": id, "parent": notion.Parent{Type: notion.ParentTypePage, PageID: parentID},
+			"properties": map[string]interface{}{"title": notion.PageTitle{Title: richText(title)}},
+		}
+	}
+	childPage := func(id, title string) notion.Block {
+		return notion.Block{Object: "block", ID: id, Type: notion.BlockTypeChildPage, HasChildren: true, ChildPage: &notion.ChildPage{Title: title}}
+	}
+	fetcher := newTestFetcher(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/pages/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa":
+			_ = json.NewEncoder(w).Encode(page("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "", "Docs"))
+		case "/v1/pages/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb":
+			_ = json.NewEncoder(w).Encode(page("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "Guide"))
+		case "/v1/pages/cccccccccccccccccccccccccccccccc":
+			_ = json.NewEncoder(w).Encode(page("cccccccccccccccccccccccccccccccc", "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", "First Step"))
+		case "/v1/blocks/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa/children":
+			writeBlocks(w, childPage("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", "Guide"))
+		case "/v1/blocks/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb/children":
+			writeBlocks(w, childPage("cccccccccccccccccccccccccccccccc", "First Step"))
+		case "/v1/blocks/cccccccccccccccccccccccccccccccc/children":
+			writeBlocks(w)
+		default:
+			t.Errorf("unexpected request: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	config := Job{
+		Notion:   Notion{RootPageID: "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"},
+		Markdown: Markdown{ShortcodeSyntax: "vuepress", PostSavePath: t.TempDir()},
+	}
+	title, err := runPageTree(fetcher, config, storage.NewLocal(t.TempDir(), "/images"), &output{})
+	assert.Nil(t, err)
+	assert.Equal(t, "Docs", title)
+
+	read := func(name string) string {
+		content, err := ioutil.ReadFile(filepath.Join(config.Markdown.PostSavePath, name))
+		assert.NoError(t, err)
+		return string(content)
+	}
+	assert.Equal(t, "---\ntitle: Docs\n---\n\n[Guide](guide/README.md)\n", read("README.md"))
+	assert.Equal(t, "---\norder: 1\ntitle: Guide\n---\n\n[First Step](first-step.md)\n", read("guide/README.md"))
+	assert.Equal(t, "---\norder: 1\ntitle: First Step\n---\n\n", read("guide/first-step.md"))
+}
+
+func TestOutputDryRun(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "updated.md"), []byte("title\nold\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "unchanged.md"), []byte("same\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "stale.md"), []byte("gone\n"), 0644))
+
+	out := &output{dryRun: true, diff: true}
+	assert.NoError(t, out.writeFile(filepath.Join(dir, "updated.md"), []byte("title\nnew\n")))
+	assert.NoError(t, out.writeFile(filepath.Join(dir, "unchanged.md"), []byte("same\n")))
+	assert.NoError(t, out.writeFile(filepath.Join(dir, "2022-01-25", "created.md"), []byte("new\n")))
+	assert.NoError(t, out.findStale(dir))
+
+	kinds := make(map[string]changeKind)
+	for _, change := range out.changes {
+		kinds[filepath.Base(change.Filename)] = change.Kind
+	}
+	assert.Equal(t, map[string]changeKind{
+		"updated.md": fileUpdated, "unchanged.md": fileUnchanged, "created.md": fileCreated, "stale.md": fileStale,
+	}, kinds)
+	assert.Contains(t, out.changes[0].Diff, "-old\n+new\n")
+
+	// Nothing is written in a dry run
+	content, _ := ioutil.ReadFile(filepath.Join(dir, "updated.md"))
+	assert.Equal(t, "title\nold\n", string(content))
+	assert.NoDirExists(t, filepath.Join(dir, "2022-01-25"))
+}
+
+func TestRunJobErrors(t *testing.T) {
+	const (
+		brokenID = "00000000000000000000000000000001"
+		okID     = "00000000000000000000000000000002"
+	)
+	fetcher := newTestFetcher(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/databases/db/query":
+			_ = json.NewEncoder(w).Encode(notion.DatabaseQueryResponse{Results: []notion.Page{
+				databasePage(brokenID, "Broken"), databasePage(okID, "Fine"),
+			}})
+		case "/v1/blocks/" + brokenID + "/children":
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"object":"error","status":403,"code":"restricted_resource","message":"forbidden"}`))
+		case "/v1/blocks/" + okID + "/children":
+			writeBlocks(w)
+		case "/v1/databases/private/query":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"object":"error","status":404,"code":"object_not_found","message":"Could not find database"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	config := Job{Name: "blog", Notion: Notion{DatabaseID: "db"}, Markdown: Markdown{PostSavePath: t.TempDir()}}
+	out := &output{}
+	errs := runJob(fetcher, config, out, false)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, ErrorAuth, errs[0].Kind)
+		assert.Equal(t, "Broken", errs[0].Page)
+	}
+	assert.FileExists(t, filepath.Join(config.Markdown.PostSavePath, "fine.md"))
+
+	report := newReport(time.Now(), out, errs)
+	if assert.Len(t, report.Pages, 2) {
+		assert.Equal(t, "Broken", report.Pages[0].Title)
+		assert.Equal(t, ErrorAuth, report.Pages[0].Error.Kind)
+		assert.Empty(t, report.Pages[0].Files)
+		assert.Equal(t, okID, report.Pages[1].ID)
+		assert.Equal(t, []fileChange{{Filename: filepath.Join(config.Markdown.PostSavePath, "fine.md"), Kind: fileCreated}}, report.Pages[1].Files)
+		assert.Nil(t, report.Pages[1].Error)
+	}
+	assert.Equal(t, []ReportError{{Kind: ErrorAuth, Job: "blog", Page: "Broken", Message: errs[0].Err.Error()}}, report.Errors)
+
+	outputs := &bytes.Buffer{}
+	assert.NoError(t, report.WriteGitHubOutputs(outputs, "report.json"))
+	assert.Equal(t, "pages=2\nchanged-files=1\nstatus-changed=0\nerrors=1\nreport=report.json\n", outputs.String())
+
+	config.Markdown.PostSavePath = t.TempDir()
+	errs = runJob(fetcher, config, &output{}, true)
+	assert.Len(t, errs, 1)
+	assert.NoFileExists(t, filepath.Join(config.Markdown.PostSavePath, "fine.md"))
+
+	// the database isn't shared with the integration
+	config.Notion.DatabaseID = "private"
+	errs = runJob(fetcher, config, &output{}, false)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, ErrorAuth, errs[0].Kind)
+	}
+
+	errs = append(errs, renderError(errors.New("bad template")), configError(errors.New("unknown job")))
+	assert.Equal(t, ErrorConfig, errs.Kind())
+}
+
+func TestOutputWriteAtomic(t *testing.T) {
+	dir := t.TempDir()
+	out := &output{}
+	assert.NoError(t, out.writeFile(filepath.Join(dir, "post.md"), []byte("v1\n")))
+	assert.NoError(t, out.writeFile(filepath.Join(dir, "post.md"), []byte("v2\n")))
+
+	content, _ := ioutil.ReadFile(filepath.Join(dir, "post.md"))
+	assert.Equal(t, "v2\n", string(content))
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 1, "no temporary file is left")
+	assert.Equal(t, []changeKind{fileCreated, fileUpdated}, []changeKind{out.changes[0].Kind, out.changes[1].Kind})
+}
+
+func TestEditedPages(t *testing.T) {
+	at := func(minute int) time.Time { return time.Date(2022, 1, 25, 6, minute, 0, 0, time.UTC) }
+	synced := make(map[string]time.Time)
+	first := []notion.Page{{ID: "a", LastEditedTime: at(1)}, {ID: "b", LastEditedTime: at(1)}}
+	assert.Len(t, editedPages(first, synced), 2)
+	synced["a"] = at(2)
+	synced["b"] = at(2)
+
+	// b is edited in the minute of its sync, c is new
+	second := []notion.Page{{ID: "a", LastEditedTime: at(1)}, {ID: "b", LastEditedTime: at(2)}, {ID: "c", LastEditedTime: at(0)}}
+	assert.Equal(t, []string{"b", "c"}, pageIDs(editedPages(second, synced)))
+	synced["b"] = at(2).Add(10 * time.Second)
+	assert.Equal(t, []string{"b", "c"}, pageIDs(editedPages(second, synced)), "the sync of b may be before the edit, c has failed")
+	synced["b"] = at(3)
+	synced["c"] = at(3)
+	assert.Empty(t, editedPages(second, synced))
+}
+
+func pageIDs(pages []notion.Page) []string {
+	ids := make([]string, 0, len(pages))
+	for _, page := range pages {
+		ids = append(ids, page.ID)
+	}
+	return ids
+}
+
+// newPageFetcher serves the page "Hello" with a heading "World".
+func newPageFetcher(t *testing.T) *blockFetcher {
+	return newTestFetcher(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/pages/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"object": "page", "id": "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "parent": notion.Parent{Type: notion.ParentTypeWorkspace},
+				"properties": map[string]interface{}{"title": notion.PageTitle{Title: richText("Hello")}},
+			})
+		case "/v1/blocks/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa/children":
+			writeBlocks(w, notion.Block{Object: "block", Type: notion.BlockTypeHeading1, Heading1: &notion.Heading{Text: richText("World")}})
+		default:
+			t.Errorf("unexpected request: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestPreviewHandler(t *testing.T) {
+	handler := newPreviewHandler(newPageFetcher(t), Job{Markdown: Markdown{ShortcodeSyntax: "hugo"}}, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	p, err := handler.render()
+	assert.Nil(t, err)
+	assert.Equal(t, "Hello", p.Title)
+	assert.Equal(t, "title: Hello\n", p.FrontMatter)
+	assert.Equal(t, "---\ntitle: Hello\n---\n\n# World\n", p.Markdown)
+	assert.Contains(t, string(p.HTML), "<h1>World</h1>")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "<h1>World</h1>")
+	assert.Contains(t, rec.Body.String(), "# World")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/assets/missing.png", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestSplitFrontMatter(t *testing.T) {
+	frontMatter, body := splitFrontMatter([]byte("---\ntitle: a\n---\n\ncontent\n"))
+	assert.Equal(t, "title: a\n", string(frontMatter))
+	assert.Equal(t, "\ncontent\n", string(body))
+
+	frontMatter, body = splitFrontMatter([]byte("content\n"))
+	assert.Nil(t, frontMatter)
+	assert.Equal(t, "content\n", string(body))
+}
+
+func TestExportPage(t *testing.T) {
+	config := Job{Markdown: Markdown{ShortcodeSyntax: "hugo", PostSavePath: t.TempDir(), ImageSavePath: t.TempDir()}}
+	stdout := &bytes.Buffer{}
+	assert.Nil(t, exportPage(newPageFetcher(t), config, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "-", stdout, &output{}))
+	assert.Equal(t, "---\ntitle: Hello\n---\n\n# World\n", stdout.String())
+
+	out := &output{}
+	assert.Nil(t, exportPage(newPageFetcher(t), config, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "", stdout, out))
+	content, err := ioutil.ReadFile(filepath.Join(config.Markdown.PostSavePath, "hello.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, stdout.String(), string(content))
+
+	filename := filepath.Join(t.TempDir(), "page.md")
+	assert.Nil(t, exportPage(newPageFetcher(t), config, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", filename, stdout, out))
+	content, err = ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, stdout.String(), string(content))
+	assert.Len(t, out.changes, 2)
+}
+
+func TestInDatabase(t *testing.T) {
+	page := notion.Page{Parent: notion.Parent{Type: notion.ParentTypeDatabase, DatabaseID: "1a2b3c4d-5e6f-47a8-b9c0-d1e2f3a4b5c6"}}
+	assert.True(t, inDatabase(page, "1a2b3c4d5e6f47a8b9c0d1e2f3a4b5c6"))
+	assert.False(t, inDatabase(page, "ffffffffffffffffffffffffffffffff"))
+	assert.False(t, inDatabase(page, ""))
+	assert.False(t, inDatabase(notion.Page{Parent: notion.Parent{Type: notion.ParentTypePage, PageID: "1a2b3c4d5e6f47a8b9c0d1e2f3a4b5c6"}}, "1a2b3c4d5e6f47a8b9c0d1e2f3a4b5c6"))
+}
@@ -0,0 +1,187 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+
+	"github.com/bonaysoft/notion-md-gen/pkg/logger"
+	"github.com/bonaysoft/notion-md-gen/pkg/storage"
+)
+
+// Hooks are the shell commands run by the sync, e.g. to format the generated files. They're skipped in a dry run.
+type Hooks struct {
+	// run for every created or updated file, with the path and the page in the environment:
+	// NOTION_MD_GEN_FILE, NOTION_MD_GEN_CHANGE, NOTION_MD_GEN_JOB, NOTION_MD_GEN_PAGE_ID and NOTION_MD_GEN_PAGE_TITLE
+	File string `yaml:"file,omitempty"`
+	// run after the sync, with the JSON report in the stdin
+	Post string `yaml:"post,omitempty"`
+}
+
+// Git commits the files generated by the sync, the other changes of the repository are left alone.
+type Git struct {
+	Commit  bool   `yaml:"commit,omitempty"`
+	Message string `yaml:"message,omitempty"` // text/template of the commit message, with the changed .Pages and .Files
+}
+
+const defaultCommitMessage = `Sync {{len .Pages}} page(s) from Notion
+{{range .Pages}}
+- {{.Title}}{{end}}
+`
+
+// runHook runs the shell command in addition to the environment of the process.
+func runHook(command string, env []string, stdin io.Reader) error {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("hook %q: %s", command, err)
+	}
+	return nil
+}
+
+// runReportHook runs the shell command after a sync, the sync may have failed pages.
+// The JSON report is given in the stdin, and the numbers of the pages and the errors in the environment.
+func runReportHook(command string, report *Report) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	return runHook(command, []string{
+		fmt.Sprintf("NOTION_MD_GEN_PAGES=%d", len(report.Pages)),
+		fmt.Sprintf("NOTION_MD_GEN_ERRORS=%d", len(report.Errors)),
+	}, bytes.NewReader(data))
+}
+
+// runFileHooks runs the file hook for every created or updated file of the pages.
+func runFileHooks(command string, pages []PageReport) Errors {
+	var errs Errors
+	for _, page := range pages {
+		for _, file := range page.Files {
+			if file.Kind != fileCreated && file.Kind != fileUpdated {
+				continue
+			}
+
+			err := runHook(command, []string{
+				"NOTION_MD_GEN_FILE=" + file.Filename,
+				"NOTION_MD_GEN_CHANGE=" + string(file.Kind),
+				"NOTION_MD_GEN_JOB=" + page.Job,
+				"NOTION_MD_GEN_PAGE_ID=" + page.ID,
+				"NOTION_MD_GEN_PAGE_TITLE=" + page.Title,
+			}, nil)
+			if err != nil {
+				errs = append(errs, &Error{Kind: ErrorRender, Job: page.Job, Page: page.Title, Err: err})
+			}
+		}
+	}
+
+	return errs
+}
+
+// commitFiles commits the changed files and the locally saved assets of the pages,
+// nothing is committed if none of them changed.
+func commitFiles(config Git, jobs []Job, pages []PageReport) error {
+	changed := struct {
+		Pages []PageReport
+		Files []string
+	}{}
+	for _, page := range pages {
+		files := make([]fileChange, 0, len(page.Files))
+		for _, file := range page.Files {
+			if file.Kind == fileCreated || file.Kind == fileUpdated {
+				files = append(files, file)
+				changed.Files = append(changed.Files, file.Filename)
+			}
+		}
+		if len(files) > 0 {
+			page.Files = files
+			changed.Pages = append(changed.Pages, page)
+		}
+	}
+
+	paths := append([]string{}, changed.Files...)
+	for _, job := range jobs {
+		if job.Markdown.ImageStorage != "" && job.Markdown.ImageStorage != "local" {
+			continue
+		}
+		if _, err := os.Stat(job.Markdown.ImageSavePath); err != nil {
+			continue
+		}
+		// check-ignore exits with 0 if the path is ignored
+		if _, err := git("check-ignore", "-q", job.Markdown.ImageSavePath); err == nil {
+			continue
+		}
+
+		store := storage.NewLocal(job.Markdown.ImageSavePath, job.Markdown.ImagePublicLink)
+		saved := make(map[string]bool)
+		for _, page := range pages {
+			if page.Job != job.Name {
+				continue
+			}
+			for _, asset := range page.Assets {
+				if filename, ok := store.Filename(asset); ok && !saved[filename] {
+					saved[filename] = true
+					paths = append(paths, filename)
+				}
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	if _, err := git(append([]string{"add", "--"}, paths...)...); err != nil {
+		return err
+	}
+	staged, err := git(append([]string{"diff", "--cached", "--name-only", "-z", "--"}, paths...)...)
+	if err != nil {
+		return err
+	}
+	staged = strings.TrimSuffix(staged, "\x00")
+	if staged == "" {
+		logger.Info("Nothing to commit")
+		return nil
+	}
+
+	message := config.Message
+	if message == "" {
+		message = defaultCommitMessage
+	}
+	tpl, err := template.New("message").Parse(message)
+	if err != nil {
+		return fmt.Errorf("invalid commit message template: %s", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := tpl.Execute(buf, changed); err != nil {
+		return fmt.Errorf("invalid commit message template: %s", err)
+	}
+
+	// commit only the staged files of the paths, even if other changes are staged.
+	// The names are relative to the top of the repository.
+	args := []string{"commit", "-m", buf.String(), "--"}
+	for _, name := range strings.Split(staged, "\x00") {
+		args = append(args, ":(top)"+name)
+	}
+	if _, err := git(args...); err != nil {
+		return err
+	}
+	logger.Info("✔ Committing files: Completed", "pages", len(changed.Pages), "files", len(changed.Files))
+	return nil
+}
+
+// git runs the git command in the working directory and returns its output.
+func git(args ...string) (string, error) {
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
@@ -0,0 +1,77 @@
+package generator
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunFileHooks(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "hook")
+	pages := []PageReport{{Job: "blog", ID: "id", Title: "Hello", Files: []fileChange{
+		{Filename: "posts/hello.md", Kind: fileCreated},
+		{Filename: "posts/same.md", Kind: fileUnchanged},
+	}}}
+
+	errs := runFileHooks(`echo "$NOTION_MD_GEN_FILE $NOTION_MD_GEN_CHANGE $NOTION_MD_GEN_JOB $NOTION_MD_GEN_PAGE_ID $NOTION_MD_GEN_PAGE_TITLE" >> `+output, pages)
+	assert.Empty(t, errs)
+	content, err := ioutil.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "posts/hello.md created blog id Hello\n", string(content))
+
+	errs = runFileHooks("exit 1", pages)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, ErrorRender, errs[0].Kind)
+	assert.Equal(t, "Hello", errs[0].Page)
+}
+
+func TestCommitFiles(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	run := func(args ...string) string {
+		out, err := exec.Command("git", args...).CombinedOutput()
+		assert.NoError(t, err, string(out))
+		return string(out)
+	}
+	run("init", "-q")
+	run("config", "user.name", "test")
+	run("config", "user.email", "test@example.com")
+	write := func(name, content string) {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+		assert.NoError(t, ioutil.WriteFile(name, []byte(content), 0644))
+	}
+	write("README.md", "readme")
+	run("add", "README.md")
+	run("commit", "-q", "-m", "init")
+
+	write("README.md", "edited by hand")
+	run("add", "README.md")
+	write("posts/hello.md", "hello")
+	write("posts/other.md", "not generated")
+	write("static/images/hello world.png", "png")
+	write("static/images/leftover.png", "not saved by the sync")
+	jobs := []Job{{Markdown: Markdown{PostSavePath: "posts", ImageSavePath: "static/images", ImagePublicLink: "/images"}}}
+	pages := []PageReport{
+		{Title: "Hello", Files: []fileChange{{Filename: "posts/hello.md", Kind: fileCreated}}, Assets: []string{"/images/hello%20world.png"}},
+		{Title: "Same", Files: []fileChange{{Filename: "posts/same.md", Kind: fileUnchanged}}},
+	}
+	assert.NoError(t, commitFiles(Git{Commit: true}, jobs, pages))
+
+	assert.Equal(t, "Sync 1 page(s) from Notion\n\n- Hello\n\n", run("log", "-1", "--format=%B"))
+	assert.ElementsMatch(t, []string{"posts/hello.md", "static/images/hello world.png"}, strings.Split(strings.TrimSpace(run("show", "--name-only", "--format=")), "\n"))
+	// the other changes are left alone
+	assert.Equal(t, "M  README.md\n?? posts/other.md\n?? static/images/leftover.png\n", run("status", "--porcelain"))
+
+	// nothing changed
+	assert.NoError(t, commitFiles(Git{Commit: true, Message: "{{len .Files}} files"}, jobs, pages))
+	assert.Equal(t, "2", strings.TrimSpace(run("rev-list", "--count", "HEAD")))
+}
@@ -0,0 +1,336 @@
+package generator
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bonaysoft/notion-md-gen/pkg/tomarkdown"
+	"github.com/dstotijn/go-notion"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configFilename = "notion-md-gen.yaml"
+	envFilename    = ".env"
+	schemaComment  = "# yaml-language-server: $schema=https://raw.githubusercontent.com/bonaysoft/notion-md-gen/master/schema/notion-md-gen.schema.json\n"
+)
+
+// PlaceholderSecret is the NOTION_SECRET of the .env created without a token, it's to be replaced by the real secret.
+const PlaceholderSecret = "xxxx"
+
+// InitOptions are the options of creating the config files.
+type InitOptions struct {
+	Dir        string // the directory of the site, default is the working directory
+	Token      string // the secret of the integration, the database and its status values are picked interactively if set
+	Force      bool   // overwrite the existing config files
+	Shortcodes bool   // scaffold the shortcodes of the static site generator, see Scaffold
+	In         io.Reader
+	Out        io.Writer
+}
+
+// Init creates the config file and the .env file in the directory of the site.
+// The shortcodeSyntax and the paths are detected from the static site generator of the site,
+// whose shortcodes are scaffolded if asked.
+func Init(opts InitOptions) error {
+	var fetcher *blockFetcher
+	if opts.Token != "" {
+		fetcher = newBlockFetcher(opts.Token, newHTTPClient())
+	}
+	return initConfig(fetcher, opts)
+}
+
+func initConfig(fetcher *blockFetcher, opts InitOptions) error {
+	configFile, envFile := filepath.Join(opts.Dir, configFilename), filepath.Join(opts.Dir, envFilename)
+	if !opts.Force {
+		for _, filename := range []string{configFile, envFile} {
+			if _, err := os.Stat(filename); err == nil {
+				return fmt.Errorf("%s already exists, run with --force to overwrite it", filename)
+			}
+		}
+	}
+
+	config := Config{
+		Notion: Notion{
+			DatabaseID:     "YOUR-NOTION-DATABASE-ID",
+			FilterProp:     "Status",
+			FilterValue:    []string{"Finished", "Published"},
+			PublishedValue: "Published",
+		},
+		Markdown: detectSite(opts.Dir),
+	}
+	if config.Markdown.ShortcodeSyntax != "" {
+		fmt.Fprintf(opts.Out, "Detected a %s site\n", config.Markdown.ShortcodeSyntax)
+	} else {
+		config.Markdown = Markdown{ShortcodeSyntax: "vuepress", PostSavePath: "posts/notion", ImageSavePath: "static/images/notion", ImagePublicLink: "/images/notion"}
+	}
+	var shortcodes map[string][]byte
+	target, _ := tomarkdown.LookupTarget(config.Markdown.ShortcodeSyntax)
+	if opts.Shortcodes {
+		var err error
+		if shortcodes, err = planScaffold(opts.Dir, target, opts.Force); err != nil {
+			return err
+		}
+	}
+
+	token := PlaceholderSecret
+	if fetcher != nil {
+		token = opts.Token
+		p := &prompter{in: bufio.NewReader(opts.In), out: opts.Out}
+		if err := pickDatabase(fetcher, p, &config.Notion); err != nil {
+			return err
+		}
+	}
+
+	out, err := yaml.Marshal(&config)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(configFile, append([]byte(schemaComment), out...), 0644); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(envFile, []byte("NOTION_SECRET="+token+"\n"), 0600); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(opts.Out, "Config file %s and %s created, keep %s out of the version control.\n", configFilename, envFilename, envFilename)
+	if shortcodes != nil {
+		if err := writeScaffold(shortcodes, target, opts.Out); err != nil {
+			return err
+		}
+	}
+	if fetcher == nil {
+		fmt.Fprintln(opts.Out, "Please edit them for yourself, or run `notion-md-gen init --force --token <secret>` to pick the database.")
+	}
+	return nil
+}
+
+// detectSite returns the markdown settings of the static site generator found in the directory,
+// the shortcodeSyntax is empty if none is found.
+func detectSite(dir string) Markdown {
+	switch {
+	case isHugo(dir):
+		return Markdown{ShortcodeSyntax: "hugo", PostSavePath: "content/posts", ImageSavePath: "static/images/notion", ImagePublicLink: "/images/notion"}
+	case exists(dir, "_config.yml") && dependsOn(dir, "hexo"):
+		return Markdown{ShortcodeSyntax: "hexo", PostSavePath: "source/_posts", ImageSavePath: "source/images/notion", ImagePublicLink: "/images/notion"}
+	case exists(dir, ".vuepress"):
+		return Markdown{ShortcodeSyntax: "vuepress", PostSavePath: "posts", ImageSavePath: ".vuepress/public/images/notion", ImagePublicLink: "/images/notion"}
+	case exists(dir, "docs/.vuepress"):
+		return Markdown{ShortcodeSyntax: "vuepress", PostSavePath: "docs/posts", ImageSavePath: "docs/.vuepress/public/images/notion", ImagePublicLink: "/images/notion"}
+	}
+	return Markdown{}
+}
+
+// isHugo returns true if the directory has the config file of hugo. The config.* files before hugo 0.110
+// are common to many tools, so they count only along with a directory of the hugo site layout.
+func isHugo(dir string) bool {
+	for _, ext := range []string{"toml", "yaml", "json"} {
+		if exists(dir, "hugo."+ext) {
+			return true
+		}
+		if exists(dir, "config."+ext) && (exists(dir, "archetypes") || exists(dir, "layouts") || exists(dir, "themes")) {
+			return true
+		}
+	}
+	return false
+}
+
+// dependsOn returns true if the package.json of the directory depends on the package,
+// e.g. a _config.yml is shared by hexo and jekyll.
+func dependsOn(dir, pkg string) bool {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return false
+	}
+	var manifest struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return false
+	}
+	_, ok := manifest.Dependencies[pkg]
+	_, dev := manifest.DevDependencies[pkg]
+	return ok || dev
+}
+
+// exists returns true if the file of the slash-separated name exists in the directory.
+func exists(dir, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+	return err == nil
+}
+
+// pickDatabase lets the user pick one of the databases shared with the integration, and the values of its status property.
+func pickDatabase(fetcher *blockFetcher, p *prompter, config *Notion) error {
+	databases, err := searchDatabases(fetcher)
+	if err != nil {
+		return fmt.Errorf("couldn't list the databases: %s", err)
+	}
+	if len(databases) == 0 {
+		return fmt.Errorf("no database is shared with the integration, %s", shareFix)
+	}
+
+	titles := make([]string, 0, len(databases))
+	for _, db := range databases {
+		titles = append(titles, fmt.Sprintf("%s (%s)", db.title, db.id))
+	}
+	db := databases[p.choose("Database", titles)]
+	config.DatabaseID = db.id
+
+	var schema databaseSchema
+	if err := fetcher.raw.do(http.MethodGet, "/databases/"+db.id, nil, &schema); err != nil {
+		return fmt.Errorf("couldn't retrieve the database: %s", err)
+	}
+	var props []string
+	for _, name := range schema.propertyNames() {
+		switch notion.DatabasePropertyType(schema.Properties[name].Type) {
+		case notion.DBPropTypeSelect, dbPropTypeStatus, notion.DBPropTypeMultiSelect, notion.DBPropTypeCheckbox:
+			props = append(props, name)
+		}
+	}
+	config.FilterProp, config.FilterValue, config.PublishedValue = "", nil, ""
+	if len(props) == 0 {
+		fmt.Fprintln(p.out, "The database has no select, status, multi_select or checkbox property, all its pages will be synced.")
+		return nil
+	}
+
+	choice := p.choose("Status property filtering the pages to sync", append([]string{"none, sync all the pages"}, props...))
+	if choice == 0 {
+		return nil
+	}
+	config.FilterProp = props[choice-1]
+	prop := schema.Properties[config.FilterProp]
+	var options *propertyOptions
+	switch notion.DatabasePropertyType(prop.Type) {
+	case notion.DBPropTypeSelect:
+		options = prop.Select
+	case dbPropTypeStatus:
+		options = prop.Status
+	case notion.DBPropTypeMultiSelect:
+		options = prop.MultiSelect
+	default:
+		config.FilterValue = []string{"true"}
+		return nil
+	}
+
+	var values []string
+	if options != nil {
+		for _, option := range options.Options {
+			values = append(values, option.Name)
+		}
+	}
+	if len(values) == 0 {
+		fmt.Fprintf(p.out, "The property %s has no option yet, set the filterValue and the publishedValue later.\n", config.FilterProp)
+		return nil
+	}
+	for _, i := range p.chooseMany("Values of the pages to sync", values) {
+		config.FilterValue = append(config.FilterValue, values[i])
+	}
+	if choice := p.choose("Value set after the sync", append([]string{"none, leave the value"}, values...)); choice > 0 {
+		config.PublishedValue = values[choice-1]
+	}
+	return nil
+}
+
+type searchedDatabase struct {
+	id    string
+	title string
+}
+
+// searchDatabases returns all the databases shared with the integration.
+func searchDatabases(fetcher *blockFetcher) ([]searchedDatabase, error) {
+	var databases []searchedDatabase
+	cursor := ""
+	for {
+		body := map[string]interface{}{"filter": map[string]interface{}{"property": "object", "value": "database"}}
+		if cursor != "" {
+			body["start_cursor"] = cursor
+		}
+		var res struct {
+			Results []struct {
+				ID    string            `json:"id"`
+				Title []notion.RichText `json:"title"`
+			} `json:"results"`
+			HasMore    bool    `json:"has_more"`
+			NextCursor *string `json:"next_cursor"`
+		}
+		if err := fetcher.raw.do(http.MethodPost, "/search", body, &res); err != nil {
+			return nil, err
+		}
+
+		for _, db := range res.Results {
+			id, _ := tomarkdown.ParseNotionID(db.ID)
+			databases = append(databases, searchedDatabase{id: id, title: tomarkdown.ConvertPlainText(db.Title)})
+		}
+		if !res.HasMore || res.NextCursor == nil {
+			return databases, nil
+		}
+		cursor = *res.NextCursor
+	}
+}
+
+// prompter asks the user to choose among the numbered items, the first item is the default.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (p *prompter) ask(question string) string {
+	fmt.Fprintf(p.out, "%s: ", question)
+	line, _ := p.in.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+func (p *prompter) list(question string, items []string) {
+	fmt.Fprintf(p.out, "%s\n", question)
+	for i, item := range items {
+		fmt.Fprintf(p.out, "  %d) %s\n", i+1, item)
+	}
+}
+
+// choose returns the index of the chosen item.
+func (p *prompter) choose(question string, items []string) int {
+	p.list(question, items)
+	for {
+		answer := p.ask("Choose a number [1]")
+		if answer == "" {
+			return 0
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(items) {
+			return n - 1
+		}
+		fmt.Fprintf(p.out, "Invalid choice %q, choose between 1 and %d\n", answer, len(items))
+	}
+}
+
+// chooseMany returns the indexes of the items chosen by the comma separated numbers.
+func (p *prompter) chooseMany(question string, items []string) []int {
+	p.list(question, items)
+	for {
+		answer := p.ask("Choose the numbers separated by commas [1]")
+		if answer == "" {
+			return []int{0}
+		}
+
+		var chosen []int
+		for _, s := range strings.Split(answer, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil || n < 1 || n > len(items) {
+				chosen = nil
+				break
+			}
+			chosen = append(chosen, n-1)
+		}
+		if chosen != nil {
+			return chosen
+		}
+		fmt.Fprintf(p.out, "Invalid choice %q, choose between 1 and %d\n", answer, len(items))
+	}
+}
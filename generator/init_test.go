@@ -0,0 +1,95 @@
+package generator
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInit(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "hugo.toml"), nil, 0644))
+	out := &bytes.Buffer{}
+	assert.NoError(t, initConfig(nil, InitOptions{Dir: dir, Out: out}))
+	assert.Contains(t, out.String(), "Detected a hugo site")
+
+	config, err := loadConfigFile(filepath.Join(dir, configFilename))
+	assert.NoError(t, err)
+	assert.Equal(t, Markdown{ShortcodeSyntax: "hugo", PostSavePath: "content/posts", ImageSavePath: "static/images/notion", ImagePublicLink: "/images/notion"}, config.Markdown)
+	assert.Equal(t, "YOUR-NOTION-DATABASE-ID", config.DatabaseID)
+	env, err := ioutil.ReadFile(filepath.Join(dir, envFilename))
+	assert.NoError(t, err)
+	assert.Equal(t, "NOTION_SECRET=xxxx\n", string(env))
+
+	// the existing files are kept
+	assert.EqualError(t, initConfig(nil, InitOptions{Dir: dir, Out: out}), filepath.Join(dir, configFilename)+" already exists, run with --force to overwrite it")
+
+	fetcher := newTestFetcher(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /v1/search":
+			_, _ = w.Write([]byte(`{"results":[
+				{"object":"database","id":"aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa","title":[{"plain_text":"Notes"}]},
+				{"object":"database","id":"bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb","title":[{"plain_text":"Posts"}]}
+			],"has_more":false}`))
+		case "GET /v1/databases/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb":
+			_, _ = w.Write([]byte(`{"object":"database","properties":{
+				"Name":{"type":"title","title":{}},
+				"Draft":{"type":"checkbox","checkbox":{}},
+				"Status":{"type":"status","status":{"options":[{"name":"Draft"},{"name":"Ready"},{"name":"Done"},{"name":"Published"}]}}
+			}}`))
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	// database 2, property 3 (Status), values 2 and 3, published value 4 (Published), after an invalid answer
+	in := strings.NewReader("2\n3\n2, 3\n9\n5\n")
+	out.Reset()
+	assert.NoError(t, initConfig(fetcher, InitOptions{Dir: dir, Token: "secret_token", Force: true, In: in, Out: out}))
+	assert.Contains(t, out.String(), "  2) Posts (bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb)\n")
+	assert.Contains(t, out.String(), `Invalid choice "9", choose between 1 and 5`)
+
+	config, err = loadConfigFile(filepath.Join(dir, configFilename))
+	assert.NoError(t, err)
+	assert.Equal(t, Notion{DatabaseID: "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", FilterProp: "Status", FilterValue: []string{"Ready", "Done"}, PublishedValue: "Published"}, config.Notion)
+	env, err = ioutil.ReadFile(filepath.Join(dir, envFilename))
+	assert.NoError(t, err)
+	assert.Equal(t, "NOTION_SECRET=secret_token\n", string(env))
+}
+
+func TestDetectSite(t *testing.T) {
+	hexoPackage := `{"dependencies":{"hexo":"^6.3.0","hexo-renderer-marked":"^6.0.0"}}`
+	tests := map[string]struct {
+		files  map[string]string // the files of the site, the names ending with / are directories
+		syntax string
+	}{
+		"hugo":          {files: map[string]string{"hugo.toml": ""}, syntax: "hugo"},
+		"hugo legacy":   {files: map[string]string{"config.toml": "", "archetypes/": ""}, syntax: "hugo"},
+		"hugo yaml":     {files: map[string]string{"config.yaml": "", "themes/": ""}, syntax: "hugo"},
+		"config only":   {files: map[string]string{"config.yaml": ""}},
+		"hexo":          {files: map[string]string{"_config.yml": "", "package.json": hexoPackage}, syntax: "hexo"},
+		"jekyll":        {files: map[string]string{"_config.yml": "", "Gemfile": "gem \"jekyll\"\n"}},
+		"jekyll npm":    {files: map[string]string{"_config.yml": "", "package.json": `{"devDependencies":{"prettier":"^3.0.0"}}`}},
+		"vuepress":      {files: map[string]string{".vuepress/": ""}, syntax: "vuepress"},
+		"vuepress docs": {files: map[string]string{"docs/.vuepress/": ""}, syntax: "vuepress"},
+		"empty":         {},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			for filename, content := range tt.files {
+				if strings.HasSuffix(filename, "/") {
+					assert.NoError(t, os.MkdirAll(filepath.Join(dir, filename), 0755))
+					continue
+				}
+				assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, filename), []byte(content), 0644))
+			}
+			assert.Equal(t, tt.syntax, detectSite(dir).ShortcodeSyntax)
+		})
+	}
+}
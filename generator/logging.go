@@ -0,0 +1,51 @@
+package generator
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/bonaysoft/notion-md-gen/pkg/logger"
+	"github.com/briandowns/spinner"
+	"github.com/mattn/go-isatty"
+)
+
+var spin = spinner.New(spinner.CharSets[14], time.Millisecond*100)
+
+// startSpinner shows the spinner with the suffix until the returned stop is called. The spinner only shows
+// up on a terminal with the default log level, it would garble the logs of CI and the debug logs otherwise.
+func startSpinner(suffix string) (stop func()) {
+	l := logger.Default()
+	if !isatty.IsTerminal(os.Stdout.Fd()) || l.Level() != logger.LevelInfo || l.JSON() {
+		return func() {}
+	}
+
+	spin.Suffix = suffix
+	spin.Start()
+	return spin.Stop
+}
+
+// apiLogger is a http.RoundTripper which logs every request of the Notion API and its latency at debug level.
+type apiLogger struct {
+	base http.RoundTripper
+}
+
+func newAPILogger(base http.RoundTripper) *apiLogger {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &apiLogger{base: base}
+}
+
+func (l *apiLogger) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := l.base.RoundTrip(req)
+	if err != nil {
+		logger.Debug("notion api", "method", req.Method, "path", req.URL.Path, "latency", time.Since(start), "error", err)
+		return resp, err
+	}
+
+	logger.Debug("notion api", "method", req.Method, "path", req.URL.Path, "status", resp.StatusCode, "latency", time.Since(start))
+	return resp, nil
+}
@@ -1,53 +1,186 @@
 package generator
 
 import (
+	"bytes"
 	"context"
-	"log"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"time"
 
-	"github.com/briandowns/spinner"
+	"github.com/bonaysoft/notion-md-gen/pkg/logger"
+	"github.com/bonaysoft/notion-md-gen/pkg/tomarkdown"
 	"github.com/dstotijn/go-notion"
 )
 
-var spin = spinner.New(spinner.CharSets[14], time.Millisecond*100)
+func filterFromConfig(config Notion, status *statusProperty) (map[string]interface{}, error) {
+	filter, err := config.Filter.Build(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if status == nil || len(config.FilterValue) == 0 {
+		return filter, nil
+	}
+
+	selected, err := status.filter(config.FilterValue)
+	if err != nil {
+		return nil, err
+	}
+	if filter == nil {
+		return selected, nil
+	}
+
+	return map[string]interface{}{
+		"and": []interface{}{selected, filter},
+	}, nil
+}
+
+// databaseQuery is the query of a database in the JSON form of the API, so the filter can use
+// the property types go-notion doesn't support yet.
+type databaseQuery struct {
+	Filter   map[string]interface{}     `json:"filter,omitempty"`
+	Sorts    []notion.DatabaseQuerySort `json:"sorts,omitempty"`
+	PageSize int                        `json:"page_size,omitempty"`
+}
+
+func queryDatabase(fetcher *blockFetcher, config Notion, status *statusProperty) (notion.DatabaseQueryResponse, error) {
+	defer startSpinner(" Querying Notion database...")()
+
+	filter, err := filterFromConfig(config, status)
+	if err != nil {
+		return notion.DatabaseQueryResponse{}, err
+	}
+
+	query := &databaseQuery{
+		Filter:   filter,
+		Sorts:    buildSorts(config.Sorts),
+		PageSize: 100,
+	}
+	var raw json.RawMessage
+	if err := fetcher.raw.do(http.MethodPost, "/databases/"+config.DatabaseID+"/query", query, &raw); err != nil {
+		return notion.DatabaseQueryResponse{}, err
+	}
 
-func filterFromConfig(config Notion) *notion.DatabaseQueryFilter {
-	if config.FilterProp == "" || len(config.FilterValue) == 0 {
-		return nil
+	var result notion.DatabaseQueryResponse
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return result, fmt.Errorf("notion: failed to parse HTTP response: %s", err)
 	}
+	if status != nil {
+		if err := status.decodeValues(raw); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// rawClient sends the requests which go-notion can't express yet, like the filters and the values
+// of the status property. It talks to the same API version as go-notion.
+type rawClient struct {
+	apiKey     string
+	httpClient *http.Client
+}
+
+const (
+	notionBaseURL    = "https://api.notion.com/v1"
+	notionAPIVersion = "2021-08-16"
+)
+
+// do sends the request with the JSON body, a nil body sends none.
+func (c *rawClient) do(method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("notion: failed to encode request: %s", err)
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), method, notionBaseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("notion: invalid request: %s", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("Notion-Version", notionAPIVersion)
+	req.Header.Set("Content-Type", "application/json")
 
-	properties := make([]notion.DatabaseQueryFilter, len(config.FilterValue))
-	for i, val := range config.FilterValue {
-		properties[i] = notion.DatabaseQueryFilter{
-			Property: config.FilterProp,
-			Select: &notion.SelectDatabaseQueryFilter{
-				Equals: val,
-			},
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("notion: failed to make HTTP request: %s", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		apiErr := &notion.APIError{}
+		if err := json.NewDecoder(res.Body).Decode(apiErr); err != nil {
+			return fmt.Errorf("notion: failed to parse error from HTTP response: %s", err)
 		}
+		return fmt.Errorf("notion: %w", apiErr)
 	}
 
-	return &notion.DatabaseQueryFilter{
-		Or: properties,
+	if err := json.NewDecoder(res.Body).Decode(result); err != nil {
+		return fmt.Errorf("notion: failed to parse HTTP response: %s", err)
 	}
+	return nil
 }
 
-func queryDatabase(client *notion.Client, config Notion) (notion.DatabaseQueryResponse, error) {
-	spin.Suffix = " Querying Notion database..."
-	spin.Start()
-	defer spin.Stop()
+// blockFetcher retrieves the blocks tree of the pages. The children of the original synced blocks
+// are cached, since an original is usually reused across many pages.
+type blockFetcher struct {
+	client   *notion.Client
+	raw      *rawClient
+	synced   map[string][]notion.Block
+	columns  map[string]float64 // the width ratios of the columns, see tomarkdown.ToMarkdown.ColumnRatios
+	warnings []string
+}
 
-	query := &notion.DatabaseQuery{
-		Filter:   filterFromConfig(config),
-		PageSize: 100,
+func newBlockFetcher(apiKey string, httpClient *http.Client) *blockFetcher {
+	return &blockFetcher{
+		client:  notion.NewClient(apiKey, notion.WithHTTPClient(httpClient)),
+		raw:     &rawClient{apiKey: apiKey, httpClient: httpClient},
+		synced:  make(map[string][]notion.Block),
+		columns: make(map[string]float64),
 	}
-	return client.QueryDatabase(context.Background(), config.DatabaseID, query)
 }
 
-func queryBlockChildren(client *notion.Client, blockID string) (blocks []notion.Block, err error) {
-	spin.Suffix = " Fetching blocks tree..."
-	spin.Start()
-	defer spin.Stop()
-	return retrieveBlockChildren(client, blockID)
+// resetCache drops the cached originals of the synced blocks, since they may be edited meanwhile.
+func (f *blockFetcher) resetCache() {
+	f.synced = make(map[string][]notion.Block)
+}
+
+// warn logs the problem which doesn't fail the page, and keeps it for the report.
+func (f *blockFetcher) warn(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	logger.Warn(msg)
+	f.warnings = append(f.warnings, msg)
+}
+
+// queryDatabasePages returns all the pages of the database which match the filter.
+func queryDatabasePages(client *notion.Client, databaseID string, filter *notion.DatabaseQueryFilter) ([]notion.Page, error) {
+	pages := make([]notion.Page, 0)
+	query := &notion.DatabaseQuery{Filter: filter, PageSize: 100}
+	for {
+		res, err := client.QueryDatabase(context.Background(), databaseID, query)
+		if err != nil {
+			return nil, err
+		}
+
+		pages = append(pages, res.Results...)
+		if !res.HasMore || res.NextCursor == nil {
+			return pages, nil
+		}
+		query.StartCursor = *res.NextCursor
+	}
+}
+
+func queryBlockChildren(fetcher *blockFetcher, blockID string) (blocks []notion.Block, err error) {
+	defer startSpinner(" Fetching blocks tree...")()
+	return fetcher.retrieveBlockChildren(blockID)
 }
 
 func retrieveBlockChildrenLoop(client *notion.Client, blockID, cursor string) (blocks []notion.Block, err error) {
@@ -73,30 +206,44 @@ func retrieveBlockChildrenLoop(client *notion.Client, blockID, cursor string) (b
 	}
 }
 
-func retrieveBlockChildren(client *notion.Client, blockID string) (blocks []notion.Block, err error) {
-	blocks, err = retrieveBlockChildrenLoop(client, blockID, "")
+func (f *blockFetcher) retrieveBlockChildren(blockID string) (blocks []notion.Block, err error) {
+	blocks, err = retrieveBlockChildrenLoop(f.client, blockID, "")
 	if err != nil {
 		return
 	}
 
-	for _, block := range blocks {
+	for i := range blocks {
+		block := &blocks[i]
+		if block.Type == notion.BlockTypeSyncedBlock {
+			block.SyncedBlock.Children, err = f.retrieveSyncedBlockChildren(*block)
+			if err != nil {
+				return
+			}
+			block.HasChildren = len(block.SyncedBlock.Children) > 0
+			continue
+		}
+
 		if !block.HasChildren {
 			continue
 		}
 
 		switch block.Type {
 		case notion.BlockTypeParagraph:
-			block.Paragraph.Children, err = retrieveBlockChildren(client, block.ID)
+			block.Paragraph.Children, err = f.retrieveBlockChildren(block.ID)
 		case notion.BlockTypeCallout:
-			block.Callout.Children, err = retrieveBlockChildren(client, block.ID)
+			block.Callout.Children, err = f.retrieveBlockChildren(block.ID)
 		case notion.BlockTypeQuote:
-			block.Quote.Children, err = retrieveBlockChildren(client, block.ID)
+			block.Quote.Children, err = f.retrieveBlockChildren(block.ID)
 		case notion.BlockTypeBulletedListItem:
-			block.BulletedListItem.Children, err = retrieveBlockChildren(client, block.ID)
+			block.BulletedListItem.Children, err = f.retrieveBlockChildren(block.ID)
 		case notion.BlockTypeNumberedListItem:
-			block.NumberedListItem.Children, err = retrieveBlockChildren(client, block.ID)
+			block.NumberedListItem.Children, err = f.retrieveBlockChildren(block.ID)
 		case notion.BlockTypeTable:
-			block.Table.Children, err = retrieveBlockChildren(client, block.ID)
+			block.Table.Children, err = f.retrieveBlockChildren(block.ID)
+		case notion.BlockTypeColumnList:
+			block.ColumnList.Children, err = f.retrieveColumns(block.ID)
+		case notion.BlockTypeColumn:
+			block.Column.Children, err = f.retrieveBlockChildren(block.ID)
 		}
 
 		if err != nil {
@@ -107,37 +254,101 @@ func retrieveBlockChildren(client *notion.Client, blockID string) (blocks []noti
 	return blocks, nil
 }
 
-// changeStatus changes the Notion article status to the published value if set.
-// It returns true if status changed.
-func changeStatus(client *notion.Client, p notion.Page, config Notion) bool {
-	// No published value or filter prop to change
-	if config.FilterProp == "" || config.PublishedValue == "" {
-		return false
+// retrieveColumns returns the columns of a column list along with their children.
+// go-notion drops the width ratios of the columns, so they are decoded from the raw response.
+func (f *blockFetcher) retrieveColumns(columnListID string) ([]notion.Block, error) {
+	var columns []notion.Block
+	query := url.Values{"page_size": {"100"}}
+	for {
+		var raw json.RawMessage
+		if err := f.raw.do(http.MethodGet, "/blocks/"+columnListID+"/children?"+query.Encode(), nil, &raw); err != nil {
+			return nil, err
+		}
+		var res notion.BlockChildrenResponse
+		if err := json.Unmarshal(raw, &res); err != nil {
+			return nil, fmt.Errorf("notion: failed to parse HTTP response: %s", err)
+		}
+		var ratios struct {
+			Results []struct {
+				ID     string `json:"id"`
+				Column struct {
+					WidthRatio float64 `json:"width_ratio"`
+				} `json:"column"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal(raw, &ratios); err != nil {
+			return nil, fmt.Errorf("notion: failed to parse HTTP response: %s", err)
+		}
+		for _, column := range ratios.Results {
+			if id, ok := tomarkdown.ParseNotionID(column.ID); ok && column.Column.WidthRatio > 0 {
+				f.columns[id] = column.Column.WidthRatio
+			}
+		}
+
+		columns = append(columns, res.Results...)
+		if !res.HasMore || res.NextCursor == nil {
+			break
+		}
+		query.Set("start_cursor", *res.NextCursor)
 	}
 
-	if v, ok := p.Properties.(notion.DatabasePageProperties)[config.FilterProp]; ok {
-		if v.Select.Name == config.PublishedValue {
-			return false
+	for i := range columns {
+		column := &columns[i]
+		if column.Type != notion.BlockTypeColumn || !column.HasChildren {
+			continue
 		}
-	} else { // No filter prop in page, can't change it
-		return false
+		children, err := f.retrieveBlockChildren(column.ID)
+		if err != nil {
+			return nil, err
+		}
+		column.Column.Children = children
+	}
+	return columns, nil
+}
+
+// retrieveSyncedBlockChildren returns the content of a synced block. The content of a copy
+// lives in the original block referenced by synced_from.
+// Every page gets its own copy of the cached content, since the rendering rewrites the image URLs.
+func (f *blockFetcher) retrieveSyncedBlockChildren(block notion.Block) ([]notion.Block, error) {
+	originalID := block.ID
+	if block.SyncedBlock.SyncedFrom != nil {
+		originalID = block.SyncedBlock.SyncedFrom.BlockID
+	}
+	if children, ok := f.synced[originalID]; ok {
+		return copyBlocks(children)
 	}
 
-	updatedProps := make(notion.DatabasePageProperties)
-	updatedProps[config.FilterProp] = notion.DatabasePageProperty{
-		Select: &notion.SelectOptions{
-			Name: config.PublishedValue,
-		},
+	if block.SyncedBlock.SyncedFrom == nil && !block.HasChildren {
+		return nil, nil
 	}
 
-	_, err := client.UpdatePage(context.Background(), p.ID,
-		notion.UpdatePageParams{
-			DatabasePageProperties: &updatedProps,
-		},
-	)
-	if err != nil {
-		log.Println("error changing status:", err)
+	children, err := f.retrieveBlockChildren(originalID)
+	switch {
+	case errors.Is(err, notion.ErrObjectNotFound), errors.Is(err, notion.ErrRestrictedResource):
+		// The original may live in a page the integration can't access, skip it rather than abort.
+		f.warn("error resolving synced block %s: %s", originalID, err)
+		children = nil
+	case err != nil:
+		// the transient errors fail the page, and aren't cached so that the next page retries
+		return nil, fmt.Errorf("error resolving synced block %s: %w", originalID, err)
+	}
+	f.synced[originalID] = children
+	return copyBlocks(children)
+}
+
+// copyBlocks returns a deep copy of the blocks tree.
+func copyBlocks(blocks []notion.Block) ([]notion.Block, error) {
+	if blocks == nil {
+		return nil, nil
 	}
 
-	return err == nil
+	data, err := json.Marshal(blocks)
+	if err != nil {
+		return nil, err
+	}
+	var copied []notion.Block
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return nil, err
+	}
+	return copied, nil
 }
@@ -0,0 +1,313 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dstotijn/go-notion"
+	"github.com/stretchr/testify/assert"
+)
+
+// rewriteTransport sends all the requests of the Notion client to the fake server.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (rt *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = rt.target.Scheme
+	req.URL.Host = rt.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func newTestFetcher(t *testing.T, handler http.Handler) *blockFetcher {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	target, _ := url.Parse(server.URL)
+	return newBlockFetcher("secret", &http.Client{Transport: &rewriteTransport{target: target}})
+}
+
+func writeBlocks(w http.ResponseWriter, blocks ...notion.Block) {
+	_ = json.NewEncoder(w).Encode(notion.BlockChildrenResponse{Results: blocks})
+}
+
+func TestRetrieveSyncedBlockChildren(t *testing.T) {
+	paragraph := func(content string) notion.Block {
+		return notion.Block{Object: "block", Type: notion.BlockTypeParagraph, Paragraph: &notion.RichTextBlock{
+			Text: []notion.RichText{{Type: notion.RichTextTypeText, Text: &notion.Text{Content: content}}},
+		}}
+	}
+	syncedCopy := notion.Block{Object: "block", ID: "copy", Type: notion.BlockTypeSyncedBlock, HasChildren: true,
+		SyncedBlock: &notion.SyncedBlock{SyncedFrom: &notion.SyncedFrom{Type: notion.SyncedFromTypeBlockID, BlockID: "original"}},
+	}
+
+	requests := make(map[string]int)
+	fetcher := newTestFetcher(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/blocks/"), "/children")
+		requests[id]++
+		switch id {
+		case "page-a", "page-b":
+			writeBlocks(w, paragraph(id), syncedCopy)
+		case "original":
+			writeBlocks(w, paragraph("synced content"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	for _, pageID := range []string{"page-a", "page-b"} {
+		blocks, err := fetcher.retrieveBlockChildren(pageID)
+		assert.NoError(t, err)
+		assert.Len(t, blocks, 2)
+		assert.True(t, blocks[1].HasChildren)
+		assert.Len(t, blocks[1].SyncedBlock.Children, 1)
+		assert.Equal(t, "synced content", blocks[1].SyncedBlock.Children[0].Paragraph.Text[0].Text.Content)
+		// the rendering of a page doesn't change the content of the other pages
+		blocks[1].SyncedBlock.Children[0].Paragraph.Text[0].Text.Content = "rendered"
+	}
+	assert.Equal(t, 1, requests["original"])
+}
+
+func TestRetrieveSyncedBlockChildrenError(t *testing.T) {
+	syncedCopy := func(original string) notion.Block {
+		return notion.Block{Object: "block", ID: "copy", Type: notion.BlockTypeSyncedBlock, HasChildren: true,
+			SyncedBlock: &notion.SyncedBlock{SyncedFrom: &notion.SyncedFrom{Type: notion.SyncedFromTypeBlockID, BlockID: original}},
+		}
+	}
+
+	requests := make(map[string]int)
+	fetcher := newTestFetcher(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/blocks/"), "/children")
+		requests[id]++
+		switch id {
+		case "page-a":
+			writeBlocks(w, syncedCopy("private"), syncedCopy("flaky"))
+		case "private":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"object":"error","status":404,"code":"object_not_found","message":"Could not find block"}`))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte(`{"object":"error","status":502,"code":"internal_server_error","message":"Bad gateway"}`))
+		}
+	}))
+
+	// the inaccessible original is skipped with a warning, the transient error fails the page and isn't cached
+	for i := 0; i < 2; i++ {
+		_, err := fetcher.retrieveBlockChildren("page-a")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "error resolving synced block flaky")
+	}
+	assert.Equal(t, 1, requests["private"])
+	assert.Equal(t, 2, requests["flaky"])
+	assert.Len(t, fetcher.warnings, 1)
+}
+
+func TestRetrieveColumns(t *testing.T) {
+	const (
+		left  = "2f4b7c3e1a9d4c6e8b2a5d7e9f0a1b2c"
+		right = "8c1d2e3f4a5b4c6d9e7f0a1b2c3d4e5f"
+	)
+	fetcher := newTestFetcher(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/blocks/"), "/children") {
+		case "page":
+			writeBlocks(w, notion.Block{Object: "block", ID: "list", Type: notion.BlockTypeColumnList, HasChildren: true, ColumnList: &notion.ColumnList{}})
+		case "list":
+			// go-notion drops the width ratios of the columns
+			_, _ = w.Write([]byte(`{"object":"list","results":[
+				{"object":"block","id":"` + left + `","type":"column","has_children":true,"column":{"width_ratio":0.25}},
+				{"object":"block","id":"` + right + `","type":"column","has_children":true,"column":{"width_ratio":0.75}}
+			],"has_more":false,"next_cursor":null}`))
+		case left, right:
+			writeBlocks(w, notion.Block{Object: "block", Type: notion.BlockTypeDivider, Divider: &notion.Divider{}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	blocks, err := fetcher.retrieveBlockChildren("page")
+	assert.NoError(t, err)
+	columns := blocks[0].ColumnList.Children
+	assert.Len(t, columns, 2)
+	assert.Len(t, columns[0].Column.Children, 1)
+	assert.Len(t, columns[1].Column.Children, 1)
+	assert.Equal(t, map[string]float64{left: 0.25, right: 0.75}, fetcher.columns)
+}
+
+func TestChangeStatus(t *testing.T) {
+	tests := []struct {
+		propType  string
+		filter    string
+		published string
+		update    string
+	}{
+		{"select", `{"or":[{"property":"Status","select":{"equals":"Finished"}}]}`, "", `{"select":{"name":"Published"}}`},
+		{"status", `{"or":[{"property":"Status","status":{"equals":"Finished"}}]}`, `"status":{"name":"Published"}`, ""},
+		{"status", `{"or":[{"property":"Status","status":{"equals":"Finished"}}]}`, `"status":{"name":"Finished"}`, `{"status":{"name":"Published"}}`},
+		{"multi_select", `{"or":[{"property":"Status","multi_select":{"contains":"Finished"}}]}`, "", `{"multi_select":[{"name":"Published"},{"name":"go"}]}`},
+	}
+	for _, tt := range tests {
+		var filter, update string
+		fetcher := newTestFetcher(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			switch r.Method + " " + r.URL.Path {
+			case "GET /v1/databases/db":
+				fmt.Fprintf(w, `{"object":"database","id":"db","properties":{"Status":{"id":"s","type":%q}}}`, tt.propType)
+			case "POST /v1/databases/db/query":
+				var query struct{ Filter json.RawMessage }
+				_ = json.Unmarshal(body, &query)
+				filter = string(query.Filter)
+				properties := `"Tags":{"type":"multi_select","multi_select":[{"name":"go"}]}`
+				if tt.published != "" {
+					properties = `"Status":{"type":"status",` + tt.published + `}`
+				} else if tt.propType == "multi_select" {
+					properties = `"Status":{"type":"multi_select","multi_select":[{"name":"Finished"},{"name":"go"}]}`
+				}
+				fmt.Fprintf(w, `{"object":"list","results":[{"object":"page","id":"page","parent":{"type":"database_id","database_id":"db"},"properties":{%s}}]}`, properties)
+			case "PATCH /v1/pages/page":
+				var params struct{ Properties map[string]json.RawMessage }
+				_ = json.Unmarshal(body, &params)
+				update = string(params.Properties["Status"])
+				_, _ = w.Write([]byte(`{"object":"page","id":"page"}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		config := Notion{DatabaseID: "db", FilterProp: "Status", FilterValue: []string{"Finished"}, PublishedValue: "Published"}
+		db, err := fetcher.client.FindDatabaseByID(context.Background(), "db")
+		assert.NoError(t, err)
+		status, err := findStatusProperty(db, config)
+		assert.NoError(t, err)
+		q, err := queryDatabase(fetcher, config, status)
+		assert.NoError(t, err)
+		assert.JSONEq(t, tt.filter, filter)
+		assert.Len(t, q.Results, 1)
+
+		assert.Equal(t, tt.update != "", changeStatus(fetcher, q.Results[0], status, config), tt.propType)
+		if tt.update != "" {
+			assert.JSONEq(t, tt.update, update)
+		}
+	}
+}
+
+func TestRunPageStatus(t *testing.T) {
+	for _, status := range []string{"Published", "Ready"} {
+		requests := make(map[string]int)
+		fetcher := newTestFetcher(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests[r.Method+" "+r.URL.Path]++
+			switch r.Method + " " + r.URL.Path {
+			case "GET /v1/databases/db":
+				_, _ = w.Write([]byte(`{"object":"database","id":"db","properties":{"Status":{"id":"s","type":"status"}}}`))
+			case "GET /v1/pages/page":
+				fmt.Fprintf(w, `{"object":"page","id":"page","properties":{"Status":{"type":"status","status":{"name":%q}}}}`, status)
+			case "GET /v1/blocks/page/children":
+				writeBlocks(w)
+			case "PATCH /v1/pages/page":
+				_, _ = w.Write([]byte(`{"object":"page","id":"page"}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		config := Job{
+			Notion:   Notion{DatabaseID: "db", FilterProp: "Status", FilterValue: []string{"Ready", "Published"}, PublishedValue: "Published"},
+			Markdown: Markdown{PostSavePath: t.TempDir()},
+		}
+		out := &output{}
+		assert.Empty(t, runPage(fetcher, config, databasePage("page", "Hello"), out))
+		// the status of the page fetched by its ID is retrieved once, and only changed if it isn't published yet
+		assert.Equal(t, 1, requests["GET /v1/pages/page"], status)
+		changed := status != "Published"
+		patches := 0
+		if changed {
+			patches = 1
+		}
+		assert.Equal(t, patches, requests["PATCH /v1/pages/page"], status)
+		if assert.Len(t, out.pages, 1) {
+			assert.Equal(t, changed, out.pages[0].StatusChanged, status)
+		}
+	}
+}
+
+func TestStatusSelected(t *testing.T) {
+	fetcher := newTestFetcher(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"object":"page","id":"page","properties":{"Status":{"type":"status","status":{"name":"Draft"}}}}`))
+	}))
+	page := func(props notion.DatabasePageProperties) notion.Page {
+		return notion.Page{ID: "page", Properties: props}
+	}
+	checked := true
+
+	tests := []struct {
+		status   statusProperty
+		page     notion.Page
+		selected bool
+	}{
+		{statusProperty{Name: "Status", Type: notion.DBPropTypeSelect}, page(notion.DatabasePageProperties{"Status": {Select: &notion.SelectOptions{Name: "Finished"}}}), true},
+		{statusProperty{Name: "Status", Type: notion.DBPropTypeSelect}, page(notion.DatabasePageProperties{"Status": {Select: &notion.SelectOptions{Name: "Draft"}}}), false},
+		{statusProperty{Name: "Status", Type: notion.DBPropTypeSelect}, page(notion.DatabasePageProperties{}), false},
+		{statusProperty{Name: "Status", Type: dbPropTypeStatus}, page(nil), false},
+		{statusProperty{Name: "Status", Type: notion.DBPropTypeMultiSelect}, page(notion.DatabasePageProperties{"Status": {MultiSelect: []notion.SelectOptions{{Name: "go"}, {Name: "Finished"}}}}), true},
+	}
+	for _, tt := range tests {
+		selected, err := tt.status.selected(fetcher, tt.page, []string{"Finished"})
+		assert.NoError(t, err)
+		assert.Equal(t, tt.selected, selected, tt.status.Type)
+	}
+
+	checkbox := statusProperty{Name: "Status", Type: notion.DBPropTypeCheckbox}
+	for _, values := range [][]string{{"false"}, {"true"}} {
+		selected, err := checkbox.selected(fetcher, page(notion.DatabasePageProperties{"Status": {Checkbox: &checked}}), values)
+		assert.NoError(t, err)
+		assert.Equal(t, values[0] == "true", selected, values)
+	}
+}
+
+func TestWriteBack(t *testing.T) {
+	var updates []string
+	fetcher := newTestFetcher(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		updates = append(updates, string(body))
+		_, _ = w.Write([]byte(`{"object":"page","id":"page"}`))
+	}))
+
+	db := notion.Database{Properties: notion.DatabaseProperties{
+		"URL":      {Type: notion.DBPropTypeURL},
+		"Synced":   {Type: notion.DBPropTypeDate},
+		"Slug":     {Type: notion.DBPropTypeRichText},
+		"Error":    {Type: notion.DBPropTypeRichText},
+		"Category": {Type: notion.DBPropTypeSelect},
+	}}
+	config := Job{
+		Notion:   Notion{WriteBack: WriteBack{URLProp: "URL", SyncedAtProp: "Synced", SlugProp: "Slug", ErrorProp: "Error"}},
+		Markdown: Markdown{PostPublicLink: "https://example.com/posts/", GroupByMonth: true},
+	}
+	wb, err := newWriteBack(db, config)
+	assert.NoError(t, err)
+
+	page := databasePage("page", "Learn iptables #1")
+	wb.published(fetcher, newDocument(page, config.Markdown), time.Date(2022, 1, 25, 6, 46, 0, 0, time.UTC))
+	wb.failed(fetcher, page, errors.New("error getting blocks"))
+	if assert.Len(t, updates, 2) {
+		assert.JSONEq(t, `{"properties":{
+			"URL":{"url":"https://example.com/posts/learn-iptables-%231"},
+			"Synced":{"date":{"start":"2022-01-25T06:46:00Z"}},
+			"Slug":{"rich_text":[{"type":"text","text":{"content":"learn-iptables-#1"}}]},
+			"Error":{"rich_text":[]}
+		}}`, updates[0])
+		assert.JSONEq(t, `{"properties":{"Error":{"rich_text":[{"type":"text","text":{"content":"error getting blocks"}}]}}}`, updates[1])
+	}
+
+	config.Notion.WriteBack.SlugProp = "Category"
+	_, err = newWriteBack(db, config)
+	assert.EqualError(t, err, "unsupported type select of property Category (want rich_text)")
+}
@@ -0,0 +1,156 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+type changeKind string
+
+const (
+	fileCreated   changeKind = "created"
+	fileUpdated   changeKind = "updated"
+	fileUnchanged changeKind = "unchanged"
+	fileStale     changeKind = "stale" // no page generates the file anymore, e.g. the page was unpublished, the sync leaves it in place
+)
+
+// fileChange is the change of a generated file compared with the existing one.
+type fileChange struct {
+	Filename string     `json:"path"`
+	Kind     changeKind `json:"change"`
+	Diff     string     `json:"-"`
+}
+
+// output writes the generated files and records their changes.
+// In a dry run the files are only compared with the existing ones.
+type output struct {
+	dryRun  bool
+	diff    bool
+	changes []fileChange
+	pages   []PageReport
+}
+
+func (o *output) writeFile(filename string, content []byte) error {
+	change := fileChange{Filename: filename, Kind: fileCreated}
+	old, err := ioutil.ReadFile(filename)
+	switch {
+	case err == nil && bytes.Equal(old, content):
+		change.Kind = fileUnchanged
+	case err == nil:
+		change.Kind = fileUpdated
+	case !os.IsNotExist(err):
+		return fmt.Errorf("error reading file: %s", err)
+	}
+
+	if o.dryRun && o.diff && change.Kind != fileUnchanged {
+		change.Diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(old)),
+			B:        difflib.SplitLines(string(content)),
+			FromFile: "a/" + filepath.ToSlash(filename),
+			ToFile:   "b/" + filepath.ToSlash(filename),
+			Context:  3,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	o.changes = append(o.changes, change)
+
+	if o.dryRun || change.Kind == fileUnchanged {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return fmt.Errorf("couldn't create content folder: %s", err)
+	}
+	if err := writeFileAtomic(filename, content); err != nil {
+		return fmt.Errorf("error create file: %s", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes the content to a hidden temporary file next to the file, then renames it to the file.
+// So the file watchers of the static site generators never see a half-written file.
+func writeFileAtomic(filename string, content []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filename)
+}
+
+// printChanges prints the changes recorded since the given index.
+func (o *output) printChanges(since int) {
+	for _, change := range o.changes[since:] {
+		fmt.Printf("  %-9s %s\n", change.Kind, change.Filename)
+		if change.Diff != "" {
+			fmt.Print(change.Diff)
+		}
+	}
+}
+
+// findStale records the markdown files in the directories which no page generates anymore.
+// The sync never removes them, so they would be left stale.
+func (o *output) findStale(dirs ...string) error {
+	generated := make(map[string]bool, len(o.changes))
+	for _, change := range o.changes {
+		generated[filepath.Clean(change.Filename)] = true
+	}
+
+	var stale []string
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(filename string, info os.FileInfo, err error) error {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(filename, ".md") && !generated[filename] {
+				generated[filename] = true
+				stale = append(stale, filename)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	sort.Strings(stale)
+	for _, filename := range stale {
+		o.changes = append(o.changes, fileChange{Filename: filename, Kind: fileStale})
+	}
+	return nil
+}
+
+// printSummary prints the number of the files by the kind of their changes.
+func (o *output) printSummary() {
+	counts := make(map[changeKind]int)
+	for _, change := range o.changes {
+		counts[change.Kind]++
+	}
+
+	fmt.Printf("Dry run: %d created, %d updated, %d unchanged, %d stale (no page generates them, left in place)\n",
+		counts[fileCreated], counts[fileUpdated], counts[fileUnchanged], counts[fileStale])
+}
@@ -0,0 +1,84 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bonaysoft/notion-md-gen/pkg/logger"
+	"github.com/bonaysoft/notion-md-gen/pkg/storage"
+	"github.com/bonaysoft/notion-md-gen/pkg/tomarkdown"
+)
+
+// runPageTree exports the root page and all its descendants, which suits the documentation sites.
+// The root page becomes the index of the PostSavePath, and every page with children the index of its own directory,
+// it returns the title of the root page.
+func runPageTree(fetcher *blockFetcher, config Job, store storage.Storage, out *output) (string, *Error) {
+	page, err := fetcher.client.FindPageByID(context.Background(), config.Notion.RootPageID)
+	if err != nil {
+		return "", resourceError(fmt.Errorf("❌ Finding Notion root page: %w", err))
+	}
+
+	index := indexFilename(config.Markdown)
+	root := &document{
+		page:     page,
+		title:    pageTitle(page),
+		filename: index,
+		columns:  fetcher.columns,
+	}
+	root.assetKey = config.Markdown.PageNamePrefix + root.title
+	logger.Info("-- Page tree "+root.title+" --", "id", page.ID)
+
+	root.blocks, err = queryBlockChildren(fetcher, page.ID)
+	if err != nil {
+		return root.title, apiError(fmt.Errorf("error getting blocks: %w", err))
+	}
+	if err := fetchChildDocuments(fetcher, root); err != nil {
+		return root.title, apiError(fmt.Errorf("error getting child pages: %w", err))
+	}
+	root.layoutChildren(index)
+	logger.Info("✔ Getting page tree: Completed")
+
+	var trail []tomarkdown.Breadcrumb
+	if config.Markdown.Breadcrumb == "trail" {
+		trail = make([]tomarkdown.Breadcrumb, 0)
+	}
+	since := len(out.changes)
+	if err := generate(root, root.links(), config.Markdown, store, trail, out); err != nil {
+		return root.title, renderError(fmt.Errorf("error generating documents: %v", err))
+	}
+	logger.Info("✔ Generating documents: Completed")
+	if out.dryRun {
+		out.printChanges(since)
+	}
+	return root.title, nil
+}
+
+// indexFilename returns the filename of the directory index, which depends on the static site generator.
+func indexFilename(config Markdown) string {
+	if config.IndexFilename != "" {
+		return config.IndexFilename
+	}
+
+	switch config.ShortcodeSyntax {
+	case "hugo":
+		return "_index.md"
+	case "vuepress":
+		return "README.md"
+	}
+	return "index.md"
+}
+
+// sidebarKey returns the front-matter key of the sidebar ordering, which depends on the static site generator.
+func sidebarKey(config Markdown) string {
+	if config.SidebarKey != "" {
+		return config.SidebarKey
+	}
+
+	switch config.ShortcodeSyntax {
+	case "hugo":
+		return "weight"
+	case "vuepress":
+		return "order"
+	}
+	return "sidebar_position"
+}
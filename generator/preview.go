@@ -0,0 +1,255 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/bonaysoft/notion-md-gen/pkg/logger"
+	"github.com/bonaysoft/notion-md-gen/pkg/tomarkdown"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// previewAssetsPath is the URL path the assets of the previewed page are served under.
+const previewAssetsPath = "/assets/"
+
+// Preview serves the page rendered by the first selected job on addr until the context is done.
+// The page is fetched and rendered again on every reload, the assets are kept in memory and nothing is written.
+func Preview(ctx context.Context, config Config, opts Options, page, addr string) error {
+	jobs, err := config.SelectJobs(opts.Jobs...)
+	if err != nil {
+		return Errors{configError(err)}
+	}
+	pageID, ok := tomarkdown.ParseNotionID(page)
+	if !ok {
+		return Errors{configError(fmt.Errorf("invalid Notion page ID or URL: %s", page))}
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return Errors{configError(fmt.Errorf("couldn't listen on %s: %s", addr, err))}
+	}
+	fetcher := newBlockFetcher(os.Getenv("NOTION_SECRET"), newHTTPClient())
+	server := &http.Server{Handler: newPreviewHandler(fetcher, jobs[0], pageID)}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	logger.Info("Serving the preview, reload the page to render it again", "url", "http://"+listener.Addr().String(), "job", jobs[0].Name)
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// preview is a page rendered for the preview server.
+type preview struct {
+	Title       string
+	Filename    string
+	Markdown    string // the generated file, including the front matter
+	FrontMatter string
+	HTML        template.HTML // the content rendered without the front matter
+	Warnings    []string
+	Error       string
+}
+
+// previewHandler renders the page on every request, and serves the assets saved by the last rendering.
+type previewHandler struct {
+	mu      sync.Mutex
+	fetcher *blockFetcher
+	config  Job
+	pageID  string
+	assets  *memoryStorage
+}
+
+func newPreviewHandler(fetcher *blockFetcher, config Job, pageID string) *previewHandler {
+	return &previewHandler{fetcher: fetcher, config: config, pageID: pageID, assets: newMemoryStorage(previewAssetsPath)}
+}
+
+func (h *previewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, previewAssetsPath) {
+		h.assets.serve(w, strings.TrimPrefix(r.URL.Path, previewAssetsPath))
+		return
+	}
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.mu.Lock()
+	p, err := h.render()
+	h.mu.Unlock()
+	if err != nil {
+		logger.Error(err.Err.Error(), "page", h.pageID, "kind", err.Kind)
+		p.Error = err.Err.Error()
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := previewTemplate.Execute(w, p); err != nil {
+		logger.Error("error rendering the preview", "err", err)
+	}
+}
+
+// render fetches the page and renders it like the job, without the nested documents.
+func (h *previewHandler) render() (*preview, *Error) {
+	h.fetcher.resetCache()
+	h.fetcher.warnings = nil
+	p := &preview{Title: h.pageID}
+	defer func() { p.Warnings = h.fetcher.warnings }()
+
+	doc, e := fetchDocument(h.fetcher, h.config.Markdown, h.pageID)
+	if doc != nil {
+		p.Title, p.Filename = doc.title, doc.filename
+	}
+	if e != nil {
+		return p, e
+	}
+
+	h.assets.reset()
+	content, err := render(doc, nil, h.config.Markdown, h.assets, nil)
+	if err != nil {
+		return p, renderError(fmt.Errorf("error generating blog post: %v", err))
+	}
+	p.Markdown = string(content)
+	frontMatter, body := splitFrontMatter(content)
+	p.FrontMatter = string(frontMatter)
+
+	buf := &bytes.Buffer{}
+	if err := previewMarkdown.Convert(body, buf); err != nil {
+		return p, renderError(fmt.Errorf("error rendering HTML: %v", err))
+	}
+	p.HTML = template.HTML(buf.String())
+	return p, nil
+}
+
+// previewMarkdown renders the markdown like the static site generators do by default,
+// the raw HTML is kept since the templates emit it, e.g. for the figures.
+var previewMarkdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM, extension.Footnote),
+	goldmark.WithRendererOptions(html.WithUnsafe()),
+)
+
+// splitFrontMatter splits the YAML front matter from the content, the front matter is empty if there's none.
+func splitFrontMatter(content []byte) (frontMatter, body []byte) {
+	if !bytes.HasPrefix(content, []byte("---\n")) {
+		return nil, content
+	}
+	end := bytes.Index(content[4:], []byte("\n---\n"))
+	if end < 0 {
+		return nil, content
+	}
+
+	return content[4 : 4+end+1], content[4+end+5:]
+}
+
+// memoryStorage keeps the assets in memory, so that the preview can serve them without writing any file.
+type memoryStorage struct {
+	mu         sync.Mutex
+	publicLink string
+	objects    map[string][]byte
+}
+
+func newMemoryStorage(publicLink string) *memoryStorage {
+	return &memoryStorage{publicLink: publicLink, objects: make(map[string][]byte)}
+}
+
+func (m *memoryStorage) Save(key string, reader io.Reader) (string, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = data
+	return m.URL(key)
+}
+
+func (m *memoryStorage) URL(key string) (string, error) {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return m.publicLink + strings.Join(segments, "/"), nil
+}
+
+func (m *memoryStorage) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects = make(map[string][]byte)
+}
+
+func (m *memoryStorage) serve(w http.ResponseWriter, key string) {
+	m.mu.Lock()
+	data, ok := m.objects[key]
+	m.mu.Unlock()
+	if !ok {
+		http.Error(w, "asset not found", http.StatusNotFound)
+		return
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(data)
+}
+
+var previewTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} - notion-md-gen preview</title>
+<style>
+body { margin: 0; font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; }
+header { padding: 8px 16px; background: #f6f8fa; border-bottom: 1px solid #d0d7de; }
+header small { color: #57606a; }
+.error, .warnings { margin: 8px 16px; padding: 8px 16px; border-radius: 6px; }
+.error { background: #ffebe9; color: #82071e; }
+.warnings { background: #fff8c5; }
+main { display: flex; }
+main > section { flex: 1; min-width: 0; padding: 0 16px; overflow: auto; height: calc(100vh - 48px); box-sizing: border-box; }
+main > section + section { border-left: 1px solid #d0d7de; background: #f6f8fa; }
+pre { white-space: pre-wrap; word-break: break-word; font-size: 13px; }
+img { max-width: 100%; }
+</style>
+</head>
+<body>
+<header><strong>{{.Title}}</strong> <small>{{.Filename}}</small></header>
+{{- if .Error}}
+<div class="error">{{.Error}}</div>
+{{- end}}
+{{- if .Warnings}}
+<div class="warnings"><strong>Warnings</strong>
+<ul>{{range .Warnings}}<li>{{.}}</li>{{end}}</ul>
+</div>
+{{- end}}
+<main>
+<section class="html">{{.HTML}}</section>
+<section class="source">
+{{- if .FrontMatter}}
+<h4>Front matter</h4>
+<pre class="front-matter">{{.FrontMatter}}</pre>
+{{- end}}
+<h4>Markdown</h4>
+<pre class="markdown">{{.Markdown}}</pre>
+</section>
+</main>
+</body>
+</html>
+`))
@@ -0,0 +1,50 @@
+package generator
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// notionRequestInterval keeps the requests under the average rate limit of the Notion API, three requests per second.
+const notionRequestInterval = time.Second / 3
+
+// rateLimiter is a http.RoundTripper which spaces out the requests by the interval.
+type rateLimiter struct {
+	base     http.RoundTripper
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func newRateLimiter(base http.RoundTripper, interval time.Duration) *rateLimiter {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &rateLimiter{base: base, interval: interval}
+}
+
+func (l *rateLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	return l.base.RoundTrip(req)
+}
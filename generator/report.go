@@ -0,0 +1,182 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/bonaysoft/notion-md-gen/pkg/storage"
+)
+
+// Report is the machine-readable result of a run, e.g. for the CI pipelines.
+type Report struct {
+	StartedAt     time.Time     `json:"startedAt"`
+	DurationMs    int64         `json:"durationMs"`
+	DryRun        bool          `json:"dryRun"`
+	Pages         []PageReport  `json:"pages"`
+	Stale         []string      `json:"stale,omitempty"` // the files no page generates anymore and the sync leaves in place, only in a dry run
+	StatusChanged int           `json:"statusChanged"`   // the number of pages changed to the published value
+	Errors        []ReportError `json:"errors"`
+}
+
+// PageReport is the result of a page, the files include the nested documents of the page.
+type PageReport struct {
+	Job           string       `json:"job"`
+	ID            string       `json:"id"`
+	Title         string       `json:"title"`
+	Files         []fileChange `json:"files"`
+	Assets        []string     `json:"assets"`
+	StatusChanged bool         `json:"statusChanged"`
+	Warnings      []string     `json:"warnings,omitempty"`
+	Error         *ReportError `json:"error,omitempty"`
+	DurationMs    int64        `json:"durationMs"`
+}
+
+// ReportError is an error of the run, the page is empty for the errors of a job.
+type ReportError struct {
+	Kind    ErrorKind `json:"kind"`
+	Job     string    `json:"job,omitempty"`
+	Page    string    `json:"page,omitempty"`
+	Message string    `json:"message"`
+}
+
+func newReportError(err *Error) *ReportError {
+	return &ReportError{Kind: err.Kind, Job: err.Job, Page: err.Page, Message: err.Err.Error()}
+}
+
+// newReport summarizes the pages and the errors of a run.
+func newReport(startedAt time.Time, out *output, errs Errors) *Report {
+	report := &Report{
+		StartedAt:  startedAt,
+		DurationMs: time.Since(startedAt).Milliseconds(),
+		DryRun:     out.dryRun,
+		Pages:      out.pages,
+		Errors:     make([]ReportError, 0, len(errs)),
+	}
+	if report.Pages == nil {
+		report.Pages = make([]PageReport, 0)
+	}
+	for _, page := range report.Pages {
+		if page.StatusChanged {
+			report.StatusChanged++
+		}
+	}
+	for _, change := range out.changes {
+		if change.Kind == fileStale {
+			report.Stale = append(report.Stale, change.Filename)
+		}
+	}
+	for _, err := range errs {
+		report.Errors = append(report.Errors, *newReportError(err))
+	}
+
+	return report
+}
+
+// Save writes the report as JSON to the file.
+func (r *Report) Save(filename string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, append(data, '\n'), 0644)
+}
+
+// WriteGitHubOutputs writes the outputs of the GitHub Action in the format of $GITHUB_OUTPUT.
+// See: https://docs.github.com/en/actions/using-workflows/workflow-commands-for-github-actions#setting-an-output-parameter
+func (r *Report) WriteGitHubOutputs(w io.Writer, reportFile string) error {
+	changedFiles := 0
+	for _, page := range r.Pages {
+		for _, file := range page.Files {
+			if file.Kind == fileCreated || file.Kind == fileUpdated {
+				changedFiles++
+			}
+		}
+	}
+
+	outputs := []struct {
+		name  string
+		value interface{}
+	}{
+		{"pages", len(r.Pages)},
+		{"changed-files", changedFiles},
+		{"status-changed", r.StatusChanged},
+		{"errors", len(r.Errors)},
+		{"report", reportFile},
+	}
+	for _, output := range outputs {
+		if _, err := fmt.Fprintf(w, "%s=%v\n", output.name, output.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeReport saves the report if asked, and writes the outputs when running in GitHub Actions.
+func writeReport(report *Report, reportFile string) error {
+	if reportFile != "" {
+		if err := report.Save(reportFile); err != nil {
+			return fmt.Errorf("error writing report: %s", err)
+		}
+	}
+
+	filename := os.Getenv("GITHUB_OUTPUT")
+	if filename == "" {
+		return nil
+	}
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing GitHub outputs: %s", err)
+	}
+	defer f.Close()
+
+	return report.WriteGitHubOutputs(f, reportFile)
+}
+
+// assetRecorder records the URLs of the assets saved for a page.
+type assetRecorder struct {
+	storage.Storage
+	urls []string
+}
+
+func (r *assetRecorder) Save(key string, reader io.Reader) (string, error) {
+	url, err := r.Storage.Save(key, reader)
+	if err == nil {
+		r.urls = append(r.urls, url)
+	}
+	return url, err
+}
+
+// pageRecorder tracks the files, the assets and the warnings of a page for the report.
+type pageRecorder struct {
+	startedAt time.Time
+	changes   int
+	warnings  int
+	assets    *assetRecorder
+}
+
+func newPageRecorder(fetcher *blockFetcher, store storage.Storage, out *output) *pageRecorder {
+	return &pageRecorder{
+		startedAt: time.Now(),
+		changes:   len(out.changes),
+		warnings:  len(fetcher.warnings),
+		assets:    &assetRecorder{Storage: store},
+	}
+}
+
+// done adds the report of the page to the output.
+func (r *pageRecorder) done(fetcher *blockFetcher, out *output, page PageReport, err *Error) {
+	page.Files = append([]fileChange{}, out.changes[r.changes:]...)
+	page.Assets = append([]string{}, r.assets.urls...)
+	page.Warnings = fetcher.warnings[r.warnings:]
+	page.DurationMs = time.Since(r.startedAt).Milliseconds()
+	if err != nil {
+		err.Job, err.Page = page.Job, page.Title
+		page.Error = newReportError(err)
+	}
+	out.pages = append(out.pages, page)
+}
@@ -0,0 +1,111 @@
+package generator
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/bonaysoft/notion-md-gen/pkg/tomarkdown"
+)
+
+// ScaffoldOptions are the options of writing the shortcodes into the site.
+type ScaffoldOptions struct {
+	Dir    string // the directory of the site, default is the working directory
+	Target string // the static site generator, default is detected from the site
+	Force  bool   // overwrite the existing files
+	Out    io.Writer
+
+	// Targets are the targets of the config, see Config.Targets
+	Targets []Target
+}
+
+// Scaffold writes the shortcodes, the partials or the plugins rendering the extended syntax of the target into the site,
+// e.g. the layouts/shortcodes of hugo. The existing files are kept unless forced.
+func Scaffold(opts ScaffoldOptions) error {
+	if opts.Target == "" {
+		opts.Target = detectSite(opts.Dir).ShortcodeSyntax
+		if opts.Target == "" {
+			return fmt.Errorf("no hugo, hexo or vuepress site found in the directory, set the target")
+		}
+	}
+
+	targets, err := buildTargets(opts.Targets)
+	if err != nil {
+		return err
+	}
+	t, ok := lookupTarget(opts.Target, targets)
+	if !ok {
+		return fmt.Errorf("no shortcodes to scaffold for the target %q", opts.Target)
+	}
+
+	files, err := planScaffold(opts.Dir, t, opts.Force)
+	if err != nil {
+		return err
+	}
+	return writeScaffold(files, t, opts.Out)
+}
+
+// lookupTarget returns the target of the name, the targets of the config first.
+func lookupTarget(name string, targets []tomarkdown.Target) (tomarkdown.Target, bool) {
+	for _, target := range targets {
+		if target.Name == name {
+			return target, true
+		}
+	}
+	return tomarkdown.LookupTarget(name)
+}
+
+// planScaffold returns the contents of the files to scaffold by their paths,
+// it fails if one of them exists unless forced.
+func planScaffold(dir string, t tomarkdown.Target, force bool) (map[string][]byte, error) {
+	if t.Scaffold == nil {
+		return nil, fmt.Errorf("no shortcodes to scaffold for the target %q", t.Name)
+	}
+	// the vuepress sites may live in the docs directory
+	if t.Name == "vuepress" && !exists(dir, ".vuepress") && exists(dir, "docs/.vuepress") {
+		dir = filepath.Join(dir, "docs")
+	}
+
+	files := make(map[string][]byte)
+	err := fs.WalkDir(t.Scaffold, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		filename := filepath.Join(dir, filepath.FromSlash(path))
+		if _, err := os.Stat(filename); err == nil && !force {
+			return fmt.Errorf("%s already exists, run with --force to overwrite it", filename)
+		}
+		files[filename], err = fs.ReadFile(t.Scaffold, path)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+func writeScaffold(files map[string][]byte, t tomarkdown.Target, out io.Writer) error {
+	filenames := make([]string, 0, len(files))
+	for filename := range files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	for _, filename := range filenames {
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filename, files[filename], 0644); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Created %s\n", filename)
+	}
+	fmt.Fprintf(out, "The shortcodes of %s are scaffolded. %s\n", t.Name, t.ScaffoldNote)
+	return nil
+}
@@ -0,0 +1,46 @@
+package generator
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScaffold(t *testing.T) {
+	dir := t.TempDir()
+	out := &bytes.Buffer{}
+	assert.EqualError(t, Scaffold(ScaffoldOptions{Dir: dir, Out: out}), "no hugo, hexo or vuepress site found in the directory, set the target")
+	assert.EqualError(t, Scaffold(ScaffoldOptions{Dir: dir, Target: "jekyll", Out: out}), `no shortcodes to scaffold for the target "jekyll"`)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "hugo.toml"), nil, 0644))
+	assert.NoError(t, Scaffold(ScaffoldOptions{Dir: dir, Out: out}))
+	for _, name := range []string{"bookmark", "callout", "columns", "column", "toc"} {
+		assert.FileExists(t, filepath.Join(dir, "layouts", "shortcodes", name+".html"))
+	}
+	assert.Contains(t, out.String(), "Created "+filepath.Join(dir, "layouts", "shortcodes", "bookmark.html")+"\n")
+	assert.Contains(t, out.String(), "markup.goldmark.renderer.unsafe")
+
+	// the existing files are kept
+	assert.EqualError(t, Scaffold(ScaffoldOptions{Dir: dir, Out: out}), filepath.Join(dir, "layouts", "shortcodes", "bookmark.html")+" already exists, run with --force to overwrite it")
+	assert.NoError(t, Scaffold(ScaffoldOptions{Dir: dir, Force: true, Out: out}))
+
+	// the plugins of vuepress go to the .vuepress directory of the docs
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "docs", ".vuepress"), 0755))
+	assert.NoError(t, Scaffold(ScaffoldOptions{Dir: dir, Target: "vuepress", Out: out}))
+	assert.FileExists(t, filepath.Join(dir, "docs", ".vuepress", "notion-md-gen.js"))
+
+	assert.NoError(t, Scaffold(ScaffoldOptions{Dir: dir, Target: "hexo", Out: out}))
+	assert.FileExists(t, filepath.Join(dir, "scripts", "notion-md-gen.js"))
+
+	// the targets of the config
+	source := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(source, "scaffold", "src", "theme"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(source, "scaffold", "src", "theme", "Callout.js"), []byte("callout"), 0644))
+	targets := []Target{{Name: "docusaurus", Templates: source, Scaffold: filepath.Join(source, "scaffold")}}
+	assert.NoError(t, Scaffold(ScaffoldOptions{Dir: dir, Target: "docusaurus", Targets: targets, Out: out}))
+	assert.FileExists(t, filepath.Join(dir, "src", "theme", "Callout.js"))
+}
@@ -0,0 +1,229 @@
+package generator
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bonaysoft/notion-md-gen/pkg/logger"
+	"github.com/bonaysoft/notion-md-gen/pkg/tomarkdown"
+)
+
+// ServeOptions are the options of the sync server.
+type ServeOptions struct {
+	Addr   string
+	Token  string // the bearer token required by every endpoint
+	Hook   string // the shell command run after every sync, e.g. to build and deploy the site
+	Report string // the file to write the JSON report of every sync to
+}
+
+// Sync statuses of the requests.
+const (
+	syncQueued    = "queued"
+	syncRunning   = "running"
+	syncSucceeded = "succeeded"
+	syncFailed    = "failed"
+)
+
+// syncRequest is a sync requested by the endpoint, it runs the jobs or syncs the single page.
+type syncRequest struct {
+	ID         int        `json:"id"`
+	Jobs       []string   `json:"jobs,omitempty"`
+	Page       string     `json:"page,omitempty"`
+	Status     string     `json:"status"`
+	QueuedAt   time.Time  `json:"queuedAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// same returns true if the requests sync the same jobs and page.
+func (r *syncRequest) same(other *syncRequest) bool {
+	if r.Page != other.Page || len(r.Jobs) != len(other.Jobs) {
+		return false
+	}
+	for i := range r.Jobs {
+		if r.Jobs[i] != other.Jobs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Serve serves the endpoints triggering the syncs until the context is done:
+//
+//	POST /sync    queues a sync, optionally of the jobs and the page given by the "job" and "page" query parameters
+//	GET  /status  returns the running, the queued and the last finished syncs
+//	GET  /report  returns the report of the last finished sync
+//
+// The syncs run one at a time, a request equal to an already queued one is merged into it.
+func Serve(ctx context.Context, config Config, opts ServeOptions) error {
+	if opts.Token == "" {
+		return Errors{configError(fmt.Errorf("a token is required to authenticate the requests"))}
+	}
+	if _, err := config.SelectJobs(); err != nil {
+		return Errors{configError(err)}
+	}
+
+	listener, err := net.Listen("tcp", opts.Addr)
+	if err != nil {
+		return Errors{configError(fmt.Errorf("couldn't listen on %s: %s", opts.Addr, err))}
+	}
+	s := newSyncServer(config, opts)
+	go s.work(ctx)
+	server := &http.Server{Handler: s}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	logger.Info("Serving the sync endpoints", "addr", listener.Addr().String())
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// syncServer queues the requested syncs and runs them one at a time.
+type syncServer struct {
+	config Config
+	opts   ServeOptions
+	run    func(config Config, opts Options) (*Report, error)
+
+	mu      sync.Mutex
+	nextID  int
+	queue   []*syncRequest
+	running *syncRequest
+	last    *syncRequest
+	report  *Report
+	wake    chan struct{}
+}
+
+func newSyncServer(config Config, opts ServeOptions) *syncServer {
+	return &syncServer{config: config, opts: opts, run: run, nextID: 1, wake: make(chan struct{}, 1)}
+}
+
+func (s *syncServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.opts.Token)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid or missing bearer token"})
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/sync" && r.Method == http.MethodPost:
+		req := &syncRequest{Jobs: r.URL.Query()["job"], Page: r.URL.Query().Get("page")}
+		if req.Page != "" {
+			if _, ok := tomarkdown.ParseNotionID(req.Page); !ok {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid Notion page ID or URL: " + req.Page})
+				return
+			}
+		}
+		if _, err := s.config.SelectJobs(req.Jobs...); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusAccepted, s.enqueue(req))
+	case r.URL.Path == "/status" && r.Method == http.MethodGet:
+		s.mu.Lock()
+		status := map[string]interface{}{"running": s.running, "queued": append([]*syncRequest{}, s.queue...), "last": s.last}
+		writeJSON(w, http.StatusOK, status)
+		s.mu.Unlock()
+	case r.URL.Path == "/report" && r.Method == http.MethodGet:
+		s.mu.Lock()
+		report := s.report
+		s.mu.Unlock()
+		if report == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no sync has finished yet"})
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+}
+
+// enqueue queues the request, or returns the queued request which is the same.
+func (s *syncServer) enqueue(req *syncRequest) syncRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, queued := range s.queue {
+		if queued.same(req) {
+			return *queued
+		}
+	}
+
+	req.ID, req.Status, req.QueuedAt = s.nextID, syncQueued, time.Now()
+	s.nextID++
+	s.queue = append(s.queue, req)
+	logger.Info("Sync queued", "id", req.ID, "jobs", req.Jobs, "page", req.Page)
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return *req
+}
+
+// work runs the queued syncs until the context is done.
+func (s *syncServer) work(ctx context.Context) {
+	for {
+		if !s.runNext() {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.wake:
+			}
+		}
+	}
+}
+
+// runNext runs the first queued sync and its hook, it returns false if the queue is empty.
+func (s *syncServer) runNext() bool {
+	s.mu.Lock()
+	if len(s.queue) == 0 {
+		s.mu.Unlock()
+		return false
+	}
+	req := s.queue[0]
+	s.queue = s.queue[1:]
+	startedAt := time.Now()
+	req.Status, req.StartedAt = syncRunning, &startedAt
+	s.running = req
+	s.mu.Unlock()
+
+	logger.Info("Sync started", "id", req.ID)
+	report, err := s.run(s.config, Options{Jobs: req.Jobs, Page: req.Page, Report: s.opts.Report})
+	if report != nil && s.opts.Hook != "" {
+		if hookErr := runReportHook(s.opts.Hook, report); hookErr != nil {
+			logger.Error(hookErr.Error(), "id", req.ID)
+			if err == nil {
+				err = hookErr
+			}
+		}
+	}
+
+	s.mu.Lock()
+	finishedAt := time.Now()
+	req.Status, req.FinishedAt = syncSucceeded, &finishedAt
+	if err != nil {
+		req.Status, req.Error = syncFailed, err.Error()
+	}
+	s.running, s.last = nil, req
+	if report != nil {
+		s.report = report
+	}
+	s.mu.Unlock()
+	logger.Info("Sync finished", "id", req.ID, "status", req.Status)
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,77 @@
+package generator
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncServer(t *testing.T) {
+	hookOutput := filepath.Join(t.TempDir(), "hook")
+	s := newSyncServer(Config{}, ServeOptions{Token: "secret", Hook: `echo "$NOTION_MD_GEN_PAGES $NOTION_MD_GEN_ERRORS" > ` + hookOutput})
+	var runs []Options
+	s.run = func(config Config, opts Options) (*Report, error) {
+		runs = append(runs, opts)
+		if opts.Page != "" {
+			return &Report{Pages: []PageReport{{ID: opts.Page}}}, nil
+		}
+		return &Report{Errors: []ReportError{{Kind: ErrorAPI}}}, errors.New("failed")
+	}
+
+	request := func(method, target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	decode := func(rec *httptest.ResponseRecorder) map[string]interface{} {
+		v := make(map[string]interface{})
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&v))
+		return v
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "/sync", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "/sync", "wrong").Code)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, "/sync?page=invalid", "secret").Code)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, "/sync?job=unknown", "secret").Code)
+	assert.Equal(t, http.StatusNotFound, request(http.MethodGet, "/report", "secret").Code)
+
+	rec := request(http.MethodPost, "/sync", "secret")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, map[string]interface{}{"id": 1.0, "status": "queued"}, without(decode(rec), "queuedAt"))
+	// the same request is merged into the queued one
+	assert.EqualValues(t, 1, decode(request(http.MethodPost, "/sync", "secret"))["id"])
+	assert.EqualValues(t, 2, decode(request(http.MethodPost, "/sync?page=aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "secret"))["id"])
+	assert.Len(t, decode(request(http.MethodGet, "/status", "secret"))["queued"], 2)
+
+	assert.True(t, s.runNext())
+	last := decode(request(http.MethodGet, "/status", "secret"))["last"].(map[string]interface{})
+	assert.Equal(t, "failed", last["status"])
+	assert.Equal(t, "failed", last["error"])
+	hook, err := ioutil.ReadFile(hookOutput)
+	assert.NoError(t, err)
+	assert.Equal(t, "0 1\n", string(hook))
+
+	assert.True(t, s.runNext())
+	assert.False(t, s.runNext())
+	status := decode(request(http.MethodGet, "/status", "secret"))
+	assert.Nil(t, status["running"])
+	assert.Empty(t, status["queued"])
+	assert.Equal(t, "succeeded", status["last"].(map[string]interface{})["status"])
+	assert.Len(t, decode(request(http.MethodGet, "/report", "secret"))["pages"], 1)
+	assert.Equal(t, []Options{{}, {Page: "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"}}, runs)
+}
+
+func without(m map[string]interface{}, key string) map[string]interface{} {
+	delete(m, key)
+	return m
+}
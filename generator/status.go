@@ -0,0 +1,238 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/dstotijn/go-notion"
+)
+
+// dbPropTypeStatus is the status property type, which go-notion doesn't support yet.
+const dbPropTypeStatus notion.DatabasePropertyType = "status"
+
+// statusProperty is the property filtered by the filterValue and changed to the publishedValue.
+// The shape of its filter and its value depends on the type in the database schema.
+type statusProperty struct {
+	Name string
+	Type notion.DatabasePropertyType // select,status,checkbox,multi_select
+
+	// go-notion drops the values of the status type, so they are decoded from the raw response.
+	statuses map[string]string
+}
+
+// findStatusProperty detects the type of the filterProp from the database schema.
+// It returns nil if the filterProp isn't set.
+func findStatusProperty(db notion.Database, config Notion) (*statusProperty, error) {
+	if config.FilterProp == "" {
+		return nil, nil
+	}
+
+	prop, ok := db.Properties[config.FilterProp]
+	if !ok {
+		return nil, fmt.Errorf("property %s not found in the database", config.FilterProp)
+	}
+
+	switch prop.Type {
+	case notion.DBPropTypeSelect, dbPropTypeStatus, notion.DBPropTypeCheckbox, notion.DBPropTypeMultiSelect:
+		return &statusProperty{Name: config.FilterProp, Type: prop.Type}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %s of property %s (want select, status, checkbox or multi_select)", prop.Type, config.FilterProp)
+}
+
+// filter returns the filter of the pages whose property has any of the values.
+func (s *statusProperty) filter(values []string) (map[string]interface{}, error) {
+	conditions := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		var condition map[string]interface{}
+		switch s.Type {
+		case notion.DBPropTypeMultiSelect:
+			condition = map[string]interface{}{"contains": value}
+		case notion.DBPropTypeCheckbox:
+			checked, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q of checkbox %s: %s", value, s.Name, err)
+			}
+			condition = map[string]interface{}{"equals": checked}
+		default:
+			condition = map[string]interface{}{"equals": value}
+		}
+		conditions = append(conditions, map[string]interface{}{"property": s.Name, string(s.Type): condition})
+	}
+
+	return map[string]interface{}{"or": conditions}, nil
+}
+
+// statusPage is a page of the raw response, with the values of the status type.
+type statusPage struct {
+	ID         string `json:"id"`
+	Properties map[string]struct {
+		Status *notion.SelectOptions `json:"status"`
+	} `json:"properties"`
+}
+
+// decodeValues keeps the values of the status type from the raw response of a database query.
+func (s *statusProperty) decodeValues(raw []byte) error {
+	if s.Type != dbPropTypeStatus {
+		return nil
+	}
+
+	var res struct {
+		Results []statusPage `json:"results"`
+	}
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return fmt.Errorf("notion: failed to parse HTTP response: %s", err)
+	}
+
+	s.statuses = make(map[string]string, len(res.Results))
+	for _, page := range res.Results {
+		s.statuses[page.ID] = page.statusOf(s.Name)
+	}
+	return nil
+}
+
+// statusOf returns the value of the status property, or empty if it isn't set.
+func (p statusPage) statusOf(name string) string {
+	if status := p.Properties[name].Status; status != nil {
+		return status.Name
+	}
+	return ""
+}
+
+// status returns the value of the status type of the page. It's decoded from the database query,
+// or retrieved again for the page fetched by its ID, as go-notion drops it.
+func (s *statusProperty) status(fetcher *blockFetcher, p notion.Page) (string, error) {
+	if name, ok := s.statuses[p.ID]; ok {
+		return name, nil
+	}
+
+	var page statusPage
+	if err := fetcher.raw.do(http.MethodGet, "/pages/"+p.ID, nil, &page); err != nil {
+		return "", err
+	}
+	if s.statuses == nil {
+		s.statuses = make(map[string]string)
+	}
+	s.statuses[p.ID] = page.statusOf(s.Name)
+	return s.statuses[p.ID], nil
+}
+
+// selected returns true if the property of the page has any of the values, like the filter does.
+func (s *statusProperty) selected(fetcher *blockFetcher, p notion.Page, values []string) (bool, error) {
+	props, _ := p.Properties.(notion.DatabasePageProperties)
+	prop := props[s.Name]
+	var names []string
+	switch s.Type {
+	case notion.DBPropTypeSelect:
+		if prop.Select != nil {
+			names = append(names, prop.Select.Name)
+		}
+	case dbPropTypeStatus:
+		name, err := s.status(fetcher, p)
+		if err != nil {
+			return false, err
+		}
+		names = append(names, name)
+	case notion.DBPropTypeCheckbox:
+		names = append(names, strconv.FormatBool(prop.Checkbox != nil && *prop.Checkbox))
+	case notion.DBPropTypeMultiSelect:
+		for _, option := range prop.MultiSelect {
+			names = append(names, option.Name)
+		}
+	}
+
+	for _, value := range values {
+		if s.Type == notion.DBPropTypeCheckbox {
+			checked, err := strconv.ParseBool(value)
+			if err != nil {
+				return false, fmt.Errorf("invalid value %q of checkbox %s: %s", value, s.Name, err)
+			}
+			value = strconv.FormatBool(checked)
+		}
+		for _, name := range names {
+			if name == value {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// published returns the new value of the property, or false if the page is already published.
+func (s *statusProperty) published(fetcher *blockFetcher, p notion.Page, config Notion) (interface{}, bool, error) {
+	props, _ := p.Properties.(notion.DatabasePageProperties)
+	prop := props[s.Name]
+	switch s.Type {
+	case notion.DBPropTypeSelect:
+		if prop.Select != nil && prop.Select.Name == config.PublishedValue {
+			return nil, false, nil
+		}
+		return map[string]interface{}{"name": config.PublishedValue}, true, nil
+	case dbPropTypeStatus:
+		name, err := s.status(fetcher, p)
+		if err != nil {
+			return nil, false, err
+		}
+		if name == config.PublishedValue {
+			return nil, false, nil
+		}
+		return map[string]interface{}{"name": config.PublishedValue}, true, nil
+	case notion.DBPropTypeCheckbox:
+		checked, err := strconv.ParseBool(config.PublishedValue)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid value %q of checkbox %s: %s", config.PublishedValue, s.Name, err)
+		}
+		if prop.Checkbox != nil && *prop.Checkbox == checked {
+			return nil, false, nil
+		}
+		return checked, true, nil
+	}
+
+	// multi_select: replace the filter values with the published value, the other options are kept.
+	filtered := make(map[string]bool, len(config.FilterValue))
+	for _, value := range config.FilterValue {
+		filtered[value] = true
+	}
+	options := []map[string]interface{}{{"name": config.PublishedValue}}
+	for _, option := range prop.MultiSelect {
+		if option.Name == config.PublishedValue {
+			return nil, false, nil
+		}
+		if !filtered[option.Name] {
+			options = append(options, map[string]interface{}{"name": option.Name})
+		}
+	}
+	return options, true, nil
+}
+
+// changeStatus changes the Notion article status to the published value if set.
+// It returns true if status changed.
+func changeStatus(fetcher *blockFetcher, p notion.Page, status *statusProperty, config Notion) bool {
+	// No published value or filter prop to change
+	if status == nil || config.PublishedValue == "" {
+		return false
+	}
+
+	value, changed, err := status.published(fetcher, p, config)
+	if err != nil {
+		fetcher.warn("error changing status: %s", err)
+		return false
+	}
+	if !changed {
+		return false
+	}
+
+	params := map[string]interface{}{
+		"properties": map[string]interface{}{
+			status.Name: map[string]interface{}{string(status.Type): value},
+		},
+	}
+	if err := fetcher.raw.do(http.MethodPatch, "/pages/"+p.ID, params, &json.RawMessage{}); err != nil {
+		fetcher.warn("error changing status: %s", err)
+		return false
+	}
+
+	return true
+}
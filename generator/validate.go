@@ -0,0 +1,154 @@
+package generator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/bonaysoft/notion-md-gen/pkg/tomarkdown"
+)
+
+// Validate checks the required settings, the formats of the IDs, the known values of the options and the writable paths.
+// All the problems found are returned, the errors of a job have its name.
+func (c Config) Validate() Errors {
+	var errs Errors
+	problem := func(job, format string, args ...interface{}) {
+		err := configError(fmt.Errorf(format, args...))
+		err.Job = job
+		errs = append(errs, err)
+	}
+
+	names := make(map[string]bool, len(c.Jobs))
+	for i, job := range c.Jobs {
+		if job.Name == "" {
+			problem("", "jobs[%d].name is required", i)
+		} else if names[job.Name] {
+			problem(job.Name, "jobs[%d].name is duplicated", i)
+		}
+		names[job.Name] = true
+	}
+
+	jobs, _ := c.SelectJobs()
+	for _, job := range jobs {
+		for _, msg := range job.validate() {
+			problem(job.Name, "%s", msg)
+		}
+	}
+
+	if c.Git.Message != "" {
+		if _, err := template.New("message").Parse(c.Git.Message); err != nil {
+			problem("", "git.message is an invalid template: %s", err)
+		}
+	}
+	return errs
+}
+
+// validate returns the problems of the job.
+func (j Job) validate() []string {
+	var problems []string
+	check := func(failed bool, format string, args ...interface{}) {
+		if failed {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+	oneOf := func(key, value string, values ...string) {
+		for _, v := range values {
+			if value == v {
+				return
+			}
+		}
+		problems = append(problems, fmt.Sprintf("markdown.%s: unknown value %q (want %s)", key, value, strings.Join(values[1:], ", ")))
+	}
+
+	n, m := j.Notion, j.Markdown
+	switch {
+	case n.DatabaseID == "" && n.RootPageID == "":
+		problems = append(problems, "notion.databaseId or notion.rootPageId is required")
+	case n.DatabaseID != "" && n.RootPageID != "":
+		problems = append(problems, "notion.databaseId and notion.rootPageId are exclusive, set only one of them")
+	case n.DatabaseID != "":
+		problems = append(problems, validateID("notion.databaseId", n.DatabaseID)...)
+	default:
+		problems = append(problems, validateID("notion.rootPageId", n.RootPageID)...)
+	}
+	check(n.FilterProp != "" && len(n.FilterValue) == 0, "notion.filterValue is required by the filterProp")
+	check(n.FilterProp == "" && (len(n.FilterValue) > 0 || n.PublishedValue != ""), "notion.filterProp is required by the filterValue and the publishedValue")
+	check(n.WriteBack.URLProp != "" && m.PostPublicLink == "", "markdown.postPublicLink is required by the notion.writeBack.urlProp")
+
+	targets := append([]string{""}, tomarkdown.TargetNames()...)
+	for _, target := range m.targets {
+		if _, ok := tomarkdown.LookupTarget(target.Name); !ok {
+			targets = append(targets, target.Name)
+		}
+	}
+	oneOf("shortcodeSyntax", m.ShortcodeSyntax, targets...)
+	oneOf("imageStorage", m.ImageStorage, "", "local", "s3")
+	oneOf("breadcrumb", m.Breadcrumb, "", "none", "trail")
+	oneOf("columnLayout", m.ColumnLayout, "", "flatten", "flex", "shortcode")
+	oneOf("childDatabase", m.ChildDatabase, "", "list", "table")
+	if m.PostPublicLink != "" {
+		u, err := url.Parse(m.PostPublicLink)
+		check(err != nil || u.Scheme == "" || u.Host == "", "markdown.postPublicLink: %q isn't an absolute URL, e.g. https://example.com/posts", m.PostPublicLink)
+	}
+	if m.Template != "" {
+		_, err := os.Stat(m.Template)
+		check(err != nil, "markdown.template: %s", err)
+	}
+
+	check(m.PostSavePath == "", "markdown.postSavePath is required")
+	if m.PostSavePath != "" {
+		check(!writable(m.PostSavePath), "markdown.postSavePath: %s isn't writable", m.PostSavePath)
+	}
+	if m.ImageStorage == "s3" {
+		check(m.S3.Bucket == "", "markdown.s3.bucket is required by the s3 image storage")
+		check(m.S3.Endpoint == "", "markdown.s3.endpoint is required by the s3 image storage")
+	} else {
+		check(m.ImageSavePath == "", "markdown.imageSavePath is required")
+		if m.ImageSavePath != "" {
+			check(!writable(m.ImageSavePath), "markdown.imageSavePath: %s isn't writable", m.ImageSavePath)
+		}
+	}
+
+	return problems
+}
+
+// validateID checks the ID of a database or a page, the URLs are rejected with the ID they contain.
+func validateID(key, s string) []string {
+	id, ok := tomarkdown.ParseNotionID(s)
+	switch {
+	case !ok:
+		return []string{fmt.Sprintf("%s: invalid ID %q, copy the 32 characters ID from the URL of the page in Notion", key, s)}
+	case strings.Contains(s, "/"):
+		return []string{fmt.Sprintf("%s: %q is a URL, use its ID %s", key, s, id)}
+	}
+	return nil
+}
+
+// writable returns true if the directory exists and is writable, or it can be created in its nearest existing parent.
+func writable(dir string) bool {
+	dir = filepath.Clean(dir)
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return false
+			}
+			break
+		}
+		if !os.IsNotExist(err) || filepath.Dir(dir) == dir {
+			return false
+		}
+		dir = filepath.Dir(dir)
+	}
+
+	f, err := ioutil.TempFile(dir, ".notion-md-gen-")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return os.Remove(f.Name()) == nil
+}
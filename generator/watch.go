@@ -0,0 +1,99 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bonaysoft/notion-md-gen/pkg/logger"
+	"github.com/dstotijn/go-notion"
+)
+
+// Watch polls the databases of the jobs by the interval and regenerates the pages edited since the last poll,
+// until the context is done. It's meant for previewing while writing, so nothing is written back to Notion.
+func Watch(ctx context.Context, config Config, opts Options, interval time.Duration) error {
+	jobs, err := config.SelectJobs(opts.Jobs...)
+	if err != nil {
+		return Errors{configError(err)}
+	}
+
+	fetcher := newBlockFetcher(os.Getenv("NOTION_SECRET"), newHTTPClient())
+	out := &output{}
+	watched := make([]*databaseJob, 0, len(jobs))
+	for _, config := range jobs {
+		if config.Notion.RootPageID != "" {
+			logger.Warn("Skipping the page-tree job, only the database jobs can be watched", "job", config.Name)
+			continue
+		}
+
+		store, err := prepareStorage(config.Markdown, out)
+		if err != nil {
+			err.Job = config.Name
+			return Errors{err}
+		}
+		job, err := prepareDatabaseJob(fetcher, config, store)
+		if err != nil {
+			err.Job = config.Name
+			return Errors{err}
+		}
+		watched = append(watched, job)
+	}
+	if len(watched) == 0 {
+		return Errors{configError(fmt.Errorf("no database job to watch"))}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	synced := make(map[string]time.Time) // the time of the last successful sync of the pages by ID
+	for {
+		// nothing is reported by the watch, so the files and the warnings of the previous polls are dropped
+		fetcher.resetCache()
+		fetcher.warnings = nil
+		*out = output{}
+		for _, job := range watched {
+			polledAt := time.Now()
+			pages, err := job.query(fetcher)
+			if err != nil {
+				logger.Error(err.Err.Error(), "job", job.config.Name, "kind", err.Kind)
+				continue
+			}
+
+			changed := editedPages(pages, synced)
+			if len(changed) > 0 {
+				logger.Info("Regenerating the edited pages", "job", job.config.Name, "pages", len(changed))
+				since := len(out.pages)
+				job.syncPages(fetcher, changed, out, false)
+				// the failed pages are retried by the next poll
+				for _, page := range out.pages[since:] {
+					if page.Error == nil {
+						synced[page.ID] = polledAt
+					}
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// editedTimePrecision is the precision of the last edited time, Notion rounds it down to the minute.
+const editedTimePrecision = time.Minute
+
+// editedPages returns the pages which are new or may be edited since their last successful sync.
+// A page edited in the minute of its sync may be edited after it, so it's synced again until the minute is over.
+func editedPages(pages []notion.Page, synced map[string]time.Time) []notion.Page {
+	changed := make([]notion.Page, 0)
+	for _, page := range pages {
+		if at, ok := synced[page.ID]; ok && !page.LastEditedTime.Add(editedTimePrecision).After(at) {
+			continue
+		}
+		changed = append(changed, page)
+	}
+
+	return changed
+}
@@ -0,0 +1,143 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/dstotijn/go-notion"
+)
+
+// maxRichTextLength is the limit of the content of a rich text object in the API.
+const maxRichTextLength = 2000
+
+// writeBack writes the result of the sync back to the properties of the pages.
+type writeBack struct {
+	config     WriteBack
+	publicLink string
+	types      map[string]notion.DatabasePropertyType
+}
+
+// newWriteBack checks the write-back properties against the database schema.
+// It returns nil if no property is written back.
+func newWriteBack(db notion.Database, config Job) (*writeBack, error) {
+	props := config.Notion.WriteBack
+	if props == (WriteBack{}) {
+		return nil, nil
+	}
+	if props.URLProp != "" && config.Markdown.PostPublicLink == "" {
+		return nil, fmt.Errorf("the urlProp requires the postPublicLink of the markdown")
+	}
+
+	w := &writeBack{config: props, publicLink: config.Markdown.PostPublicLink, types: make(map[string]notion.DatabasePropertyType)}
+	for _, prop := range []struct {
+		name  string
+		types []notion.DatabasePropertyType
+	}{
+		{props.URLProp, []notion.DatabasePropertyType{notion.DBPropTypeURL, notion.DBPropTypeRichText}},
+		{props.SyncedAtProp, []notion.DatabasePropertyType{notion.DBPropTypeDate, notion.DBPropTypeRichText}},
+		{props.SlugProp, []notion.DatabasePropertyType{notion.DBPropTypeRichText}},
+		{props.ErrorProp, []notion.DatabasePropertyType{notion.DBPropTypeRichText}},
+	} {
+		if prop.name == "" {
+			continue
+		}
+
+		dbProp, ok := db.Properties[prop.name]
+		if !ok {
+			return nil, fmt.Errorf("property %s not found in the database", prop.name)
+		}
+		if !containsPropType(prop.types, dbProp.Type) {
+			return nil, fmt.Errorf("unsupported type %s of property %s (want %s)", dbProp.Type, prop.name, joinPropTypes(prop.types))
+		}
+		w.types[prop.name] = dbProp.Type
+	}
+
+	return w, nil
+}
+
+// published writes the URL, the sync time and the slug of the document, and clears the error.
+// The URL is made of the slug, as the permalinks of the static site generators usually leave out the month directory.
+func (w *writeBack) published(fetcher *blockFetcher, doc *document, now time.Time) {
+	if w == nil {
+		return
+	}
+
+	slug := path.Base(doc.dir)
+	properties := make(map[string]interface{})
+	w.set(properties, w.config.URLProp, strings.TrimSuffix(w.publicLink, "/")+"/"+url.PathEscape(slug))
+	w.set(properties, w.config.SyncedAtProp, now.Format(time.RFC3339))
+	w.set(properties, w.config.SlugProp, slug)
+	w.set(properties, w.config.ErrorProp, "")
+	w.update(fetcher, doc.page.ID, properties)
+}
+
+// failed writes the error of the sync, so the editors see it in Notion.
+func (w *writeBack) failed(fetcher *blockFetcher, p notion.Page, err error) {
+	if w == nil || w.config.ErrorProp == "" {
+		return
+	}
+
+	properties := make(map[string]interface{})
+	w.set(properties, w.config.ErrorProp, err.Error())
+	w.update(fetcher, p.ID, properties)
+}
+
+// set adds the value of the property in the shape of its type, the empty value clears the property.
+func (w *writeBack) set(properties map[string]interface{}, name, value string) {
+	if name == "" {
+		return
+	}
+
+	switch w.types[name] {
+	case notion.DBPropTypeURL:
+		if value == "" {
+			properties[name] = map[string]interface{}{"url": nil}
+			return
+		}
+		properties[name] = map[string]interface{}{"url": value}
+	case notion.DBPropTypeDate:
+		if value == "" {
+			properties[name] = map[string]interface{}{"date": nil}
+			return
+		}
+		properties[name] = map[string]interface{}{"date": map[string]interface{}{"start": value}}
+	default:
+		text := make([]interface{}, 0, 1)
+		if value != "" {
+			if r := []rune(value); len(r) > maxRichTextLength {
+				value = string(r[:maxRichTextLength])
+			}
+			text = append(text, map[string]interface{}{"type": "text", "text": map[string]interface{}{"content": value}})
+		}
+		properties[name] = map[string]interface{}{"rich_text": text}
+	}
+}
+
+func (w *writeBack) update(fetcher *blockFetcher, pageID string, properties map[string]interface{}) {
+	params := map[string]interface{}{"properties": properties}
+	if err := fetcher.raw.do(http.MethodPatch, "/pages/"+pageID, params, &json.RawMessage{}); err != nil {
+		fetcher.warn("error writing back properties: %s", err)
+	}
+}
+
+func containsPropType(types []notion.DatabasePropertyType, t notion.DatabasePropertyType) bool {
+	for _, typ := range types {
+		if typ == t {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPropTypes(types []notion.DatabasePropertyType) string {
+	names := make([]string, 0, len(types))
+	for _, t := range types {
+		names = append(names, string(t))
+	}
+	return strings.Join(names, " or ")
+}
@@ -0,0 +1,166 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log entry.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	}
+	return "error"
+}
+
+// Logger writes the leveled log entries in text or JSON, the fields are given as key-value pairs.
+// It also satisfies the LeveledLogger of retryablehttp.
+type Logger struct {
+	mu    sync.Mutex
+	out   io.Writer
+	level Level
+	json  bool
+	now   func() time.Time
+}
+
+// New returns a Logger writing the entries at or above the level, the format is text or json.
+func New(out io.Writer, level Level, format string) (*Logger, error) {
+	if format != "" && format != "text" && format != "json" {
+		return nil, fmt.Errorf("unknown log format: %s", format)
+	}
+
+	return &Logger{out: out, level: level, json: format == "json", now: time.Now}, nil
+}
+
+// Level returns the minimum level of the entries written.
+func (l *Logger) Level() Level {
+	return l.level
+}
+
+// JSON returns true if the entries are written in JSON.
+func (l *Logger) JSON() bool {
+	return l.json
+}
+
+func (l *Logger) Debug(msg string, keysAndValues ...interface{}) {
+	l.log(LevelDebug, msg, keysAndValues)
+}
+
+func (l *Logger) Info(msg string, keysAndValues ...interface{}) {
+	l.log(LevelInfo, msg, keysAndValues)
+}
+
+func (l *Logger) Warn(msg string, keysAndValues ...interface{}) {
+	l.log(LevelWarn, msg, keysAndValues)
+}
+
+func (l *Logger) Error(msg string, keysAndValues ...interface{}) {
+	l.log(LevelError, msg, keysAndValues)
+}
+
+func (l *Logger) log(level Level, msg string, keysAndValues []interface{}) {
+	if level < l.level {
+		return
+	}
+	if len(keysAndValues)%2 != 0 {
+		keysAndValues = append(keysAndValues, "(missing)")
+	}
+
+	buf := &bytes.Buffer{}
+	now := l.now().Format(time.RFC3339)
+	if l.json {
+		fmt.Fprintf(buf, `{"time":%s,"level":%s,"msg":%s`, jsonValue(now), jsonValue(level.String()), jsonValue(msg))
+		for i := 0; i < len(keysAndValues); i += 2 {
+			fmt.Fprintf(buf, `,%s:%s`, jsonValue(fmt.Sprint(keysAndValues[i])), jsonValue(keysAndValues[i+1]))
+		}
+		buf.WriteString("}\n")
+	} else {
+		fmt.Fprintf(buf, "%s %-5s %s", now, strings.ToUpper(level.String()), msg)
+		for i := 0; i < len(keysAndValues); i += 2 {
+			fmt.Fprintf(buf, " %v=%s", keysAndValues[i], textValue(keysAndValues[i+1]))
+		}
+		buf.WriteByte('\n')
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.out.Write(buf.Bytes())
+}
+
+func textValue(v interface{}) string {
+	s := fmt.Sprint(plainValue(v))
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func jsonValue(v interface{}) string {
+	b, err := json.Marshal(plainValue(v))
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(v))
+	}
+	return string(b)
+}
+
+// plainValue converts the values which have no useful JSON form, like errors and durations, to strings.
+func plainValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return v
+}
+
+var std, _ = New(os.Stderr, LevelInfo, "text")
+
+// Default returns the logger used by the package-level functions.
+func Default() *Logger {
+	return std
+}
+
+// SetDefault replaces the logger used by the package-level functions.
+func SetDefault(l *Logger) {
+	std = l
+}
+
+func Debug(msg string, keysAndValues ...interface{}) {
+	std.Debug(msg, keysAndValues...)
+}
+
+func Info(msg string, keysAndValues ...interface{}) {
+	std.Info(msg, keysAndValues...)
+}
+
+func Warn(msg string, keysAndValues ...interface{}) {
+	std.Warn(msg, keysAndValues...)
+}
+
+func Error(msg string, keysAndValues ...interface{}) {
+	std.Error(msg, keysAndValues...)
+}
@@ -0,0 +1,32 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l, err := New(buf, LevelInfo, "text")
+	assert.NoError(t, err)
+	l.now = func() time.Time { return time.Date(2022, 1, 25, 6, 46, 0, 0, time.UTC) }
+
+	l.Debug("notion api", "method", "GET")
+	l.Info("✔ Generating blog post: Completed", "page", "Learn iptables", "latency", 1500*time.Millisecond)
+	l.Warn("error changing status", "error", errors.New("forbidden"))
+	assert.Equal(t, "2022-01-25T06:46:00Z INFO  ✔ Generating blog post: Completed page=\"Learn iptables\" latency=1.5s\n"+
+		"2022-01-25T06:46:00Z WARN  error changing status error=forbidden\n", buf.String())
+
+	buf.Reset()
+	l, _ = New(buf, LevelDebug, "json")
+	l.now = func() time.Time { return time.Date(2022, 1, 25, 6, 46, 0, 0, time.UTC) }
+	l.Debug("notion api", "method", "GET", "status", 200, "odd")
+	assert.Equal(t, `{"time":"2022-01-25T06:46:00Z","level":"debug","msg":"notion api","method":"GET","status":200,"odd":"(missing)"}`+"\n", buf.String())
+
+	_, err = New(buf, LevelInfo, "xml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config is the config of a S3-compatible object storage, e.g. AWS S3, MinIO, Cloudflare R2.
+type S3Config struct {
+	Endpoint        string `yaml:"endpoint"` // e.g. https://s3.us-west-2.amazonaws.com
+	Region          string `yaml:"region"`
+	Bucket          string `yaml:"bucket"`
+	AccessKeyID     string `yaml:"accessKeyId,omitempty"`
+	SecretAccessKey string `yaml:"secretAccessKey,omitempty"`
+
+	// Optional:
+	Prefix    string `yaml:"prefix,omitempty"`
+	PublicURL string `yaml:"publicUrl,omitempty"` // default is the object URL of the endpoint
+	PathStyle bool   `yaml:"pathStyle,omitempty"` // required by MinIO and most self-hosted services
+	ACL       string `yaml:"acl,omitempty"`       // e.g. public-read
+}
+
+// S3 saves the assets into a bucket of a S3-compatible object storage.
+type S3 struct {
+	config S3Config
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewS3(config S3Config) (*S3, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("s3: endpoint and bucket are required")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+
+	return &S3{config: config, client: http.DefaultClient, now: time.Now}, nil
+}
+
+func (s *S3) Save(name string, reader io.Reader) (string, error) {
+	key := path.Join(s.config.Prefix, name)
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+
+	objectURL, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, objectURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = int64(len(body))
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	} else {
+		req.Header.Set("Content-Type", http.DetectContentType(body))
+	}
+	if s.config.ACL != "" {
+		req.Header.Set("X-Amz-Acl", s.config.ACL)
+	}
+	s.sign(req, body)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("s3: put object %s: %s", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("s3: put object %s: %s: %s", key, resp.Status, bytes.TrimSpace(msg))
+	}
+
+	return s.URL(name)
+}
+
+func (s *S3) URL(name string) (string, error) {
+	key := path.Join(s.config.Prefix, name)
+	if s.config.PublicURL != "" {
+		return strings.TrimSuffix(s.config.PublicURL, "/") + "/" + escapeKey(key), nil
+	}
+
+	objectURL, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+	return objectURL.String(), nil
+}
+
+func (s *S3) objectURL(key string) (*url.URL, error) {
+	u, err := url.Parse(s.config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("s3: malformed endpoint: %s", err)
+	}
+
+	if s.config.PathStyle {
+		u.Path = path.Join("/", u.Path, s.config.Bucket, key)
+	} else {
+		u.Host = s.config.Bucket + "." + u.Host
+		u.Path = path.Join("/", u.Path, key)
+	}
+	u.RawPath = uriEncode(u.Path)
+	return u, nil
+}
+
+// sign signs the request with the AWS Signature Version 4.
+// See: https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (s *S3) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := new(strings.Builder)
+	for _, name := range names {
+		fmt.Fprintf(canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.config.Region, "s3", "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := deriveSigningKey(s.config.SecretAccessKey, date, s.config.Region, "s3")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, []byte(stringToSign)))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func deriveSigningKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), []byte(date))
+	key = hmacSHA256(key, []byte(region))
+	key = hmacSHA256(key, []byte(service))
+	return hmacSHA256(key, []byte("aws4_request"))
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// uriEncode encodes the object path as required by the AWS Signature Version 4.
+func uriEncode(s string) string {
+	buf := new(strings.Builder)
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~', b == '/':
+			buf.WriteByte(b)
+		default:
+			fmt.Fprintf(buf, "%%%02X", b)
+		}
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 is a minimal stand-in of a MinIO server which accepts the signed PUT requests.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	headers map[string]http.Header
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	if r.Method != http.MethodPut ||
+		!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/") ||
+		r.Header.Get("X-Amz-Content-Sha256") != sha256Hex(body) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[r.URL.EscapedPath()] = body
+	f.headers[r.URL.EscapedPath()] = r.Header
+}
+
+func TestS3Save(t *testing.T) {
+	fake := &fakeS3{objects: make(map[string][]byte), headers: make(map[string]http.Header)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	s3, err := NewS3(S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "blog",
+		AccessKeyID:     "minio",
+		SecretAccessKey: "minio123",
+		Prefix:          "images",
+		PathStyle:       true,
+	})
+	assert.NoError(t, err)
+	s3.now = func() time.Time { return time.Date(2022, 1, 25, 6, 46, 0, 0, time.UTC) }
+
+	visitURL, err := WithPrefix(s3, "learn iptables").Save("a.png", strings.NewReader("png"))
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/blog/images/learn%20iptables/a.png", visitURL)
+	assert.Equal(t, []byte("png"), fake.objects["/blog/images/learn%20iptables/a.png"])
+
+	header := fake.headers["/blog/images/learn%20iptables/a.png"]
+	assert.Equal(t, "image/png", header.Get("Content-Type"))
+	assert.Equal(t, "20220125T064600Z", header.Get("X-Amz-Date"))
+	assert.Contains(t, header.Get("Authorization"), "/20220125/us-east-1/s3/aws4_request")
+
+	s3.config.PublicURL = "https://cdn.example.com/"
+	visitURL, err = s3.Save("b.png", strings.NewReader("png"))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/images/b.png", visitURL)
+
+	s3.config.SecretAccessKey = ""
+	s3.config.AccessKeyID = "nobody"
+	_, err = s3.Save("c.png", strings.NewReader("png"))
+	assert.Error(t, err)
+}
+
+func TestDeriveSigningKey(t *testing.T) {
+	// The example from https://docs.aws.amazon.com/general/latest/gr/signature-v4-examples.html
+	key := deriveSigningKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	assert.Equal(t, "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d", hex.EncodeToString(key))
+}
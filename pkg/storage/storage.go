@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Storage saves the assets referenced by a page, e.g. images and covers.
+type Storage interface {
+	// Save writes the content of reader to the object named by key and
+	// returns the public URL the object can be visited by.
+	Save(key string, reader io.Reader) (string, error)
+
+	// URL returns the public URL of the object named by key without writing it.
+	URL(key string) (string, error)
+}
+
+// Local saves the assets into a directory of the local filesystem.
+type Local struct {
+	Dir        string
+	PublicLink string
+}
+
+func NewLocal(dir, publicLink string) *Local {
+	return &Local{Dir: dir, PublicLink: publicLink}
+}
+
+func (l *Local) Save(key string, reader io.Reader) (string, error) {
+	filename := filepath.Join(l.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return "", fmt.Errorf("%s: %s", filepath.Dir(filename), err)
+	}
+
+	out, err := os.Create(filename)
+	if err != nil {
+		return "", fmt.Errorf("couldn't create file: %s", err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, reader); err != nil {
+		return "", err
+	}
+
+	return l.URL(key)
+}
+
+func (l *Local) URL(key string) (string, error) {
+	return path.Join(l.PublicLink, escapeKey(key)), nil
+}
+
+// Filename returns the file of the object by its public URL, or false if the URL isn't one of the directory.
+func (l *Local) Filename(u string) (string, bool) {
+	prefix, _ := l.URL("")
+	key := strings.TrimPrefix(u, strings.TrimSuffix(prefix, "/")+"/")
+	if key == u {
+		return "", false
+	}
+	key, err := url.PathUnescape(key)
+	if err != nil {
+		return "", false
+	}
+
+	return filepath.Join(l.Dir, filepath.FromSlash(key)), true
+}
+
+// prefixed saves all the objects under the given key prefix.
+type prefixed struct {
+	Storage
+	prefix string
+}
+
+// WithPrefix returns a Storage that prepends prefix to every key.
+func WithPrefix(s Storage, prefix string) Storage {
+	if prefix == "" {
+		return s
+	}
+
+	return &prefixed{Storage: s, prefix: prefix}
+}
+
+func (p *prefixed) Save(key string, reader io.Reader) (string, error) {
+	return p.Storage.Save(path.Join(p.prefix, key), reader)
+}
+
+func (p *prefixed) URL(key string) (string, error) {
+	return p.Storage.URL(path.Join(p.prefix, key))
+}
+
+// discard skips the writes, e.g. in a dry run.
+type discard struct {
+	Storage
+}
+
+// Discard returns a Storage that only returns the URLs of s without writing the objects.
+func Discard(s Storage) Storage {
+	return &discard{Storage: s}
+}
+
+func (d *discard) Save(key string, reader io.Reader) (string, error) {
+	return d.Storage.URL(key)
+}
+
+// escapeKey escapes every segment of the key so that it can be used in a URL path.
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,167 @@
+package tomarkdown
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/dstotijn/go-notion"
+)
+
+// ChildDatabase is a child_database block whose entries are exported as nested documents.
+type ChildDatabase struct {
+	Title       string
+	TitleColumn string
+	Columns     []string
+	Rows        []ChildDocument
+}
+
+// ChildDocument is an entry of a child database.
+type ChildDocument struct {
+	ID         string
+	Title      string
+	Link       string
+	Properties map[string]interface{}
+}
+
+// NewChildDatabase returns the child database with its entries, the columns are all the properties except the title.
+func NewChildDatabase(title string, entries []notion.Page) ChildDatabase {
+	db := ChildDatabase{Title: title, TitleColumn: "Name", Rows: make([]ChildDocument, 0, len(entries))}
+	columns := make(map[string]bool)
+	for _, entry := range entries {
+		row := ChildDocument{ID: entry.ID, Properties: make(map[string]interface{})}
+		props, _ := entry.Properties.(notion.DatabasePageProperties)
+		for name, prop := range props {
+			if prop.Type == notion.DBPropTypeTitle {
+				db.TitleColumn, row.Title = name, ConvertPlainText(prop.Title)
+				continue
+			}
+
+			columns[name] = true
+			if v := PropertyValue(prop); v != nil {
+				row.Properties[name] = v
+			}
+		}
+		db.Rows = append(db.Rows, row)
+	}
+
+	for name := range columns {
+		db.Columns = append(db.Columns, name)
+	}
+	sort.Strings(db.Columns)
+	return db
+}
+
+// ParseNotionID extracts the ID from a Notion ID or URL, with or without hyphens.
+// The page opened in a database view, e.g. "/db-id?v=view-id&p=page-id", is preferred to the database.
+// The returned ID is always in the compact form without hyphens.
+func ParseNotionID(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if u, err := url.Parse(s); err == nil && u.Path != "" {
+		if p := u.Query().Get("p"); p != "" {
+			if id, ok := ParseNotionID(p); ok {
+				return id, true
+			}
+		}
+		s = u.Path
+	}
+	s = strings.TrimSuffix(s, "/")
+	if idx := strings.LastIndex(s, "/"); idx >= 0 {
+		s = s[idx+1:]
+	}
+
+	s = strings.ToLower(strings.ReplaceAll(s, "-", ""))
+	if len(s) < 32 {
+		return "", false
+	}
+	s = s[len(s)-32:]
+	for _, r := range s {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f') {
+			return "", false
+		}
+	}
+
+	return s, true
+}
+
+// pageLink returns the link of the exported document if the URL links to a page of Notion.
+func (tm *ToMarkdown) pageLink(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	if u.Host != "" && !strings.HasSuffix(u.Host, "notion.so") && !strings.HasSuffix(u.Host, "notion.site") {
+		return "", false
+	}
+
+	id, ok := ParseNotionID(u.Path)
+	if !ok {
+		return "", false
+	}
+
+	link, ok := tm.Links[id]
+	return link, ok
+}
+
+// convertRichText converts the rich text like ConvertRichText, but rewrites the links
+// and mentions of the exported pages to the links of their documents.
+func (tm *ToMarkdown) convertRichText(t []notion.RichText) string {
+	if len(tm.Links) == 0 {
+		return ConvertRichText(t)
+	}
+
+	buf := &bytes.Buffer{}
+	for _, word := range t {
+		switch {
+		case word.Type == notion.RichTextTypeText && word.Text != nil && word.Text.Link != nil:
+			if link, ok := tm.pageLink(word.Text.Link.URL); ok {
+				word.Text = &notion.Text{Content: word.Text.Content, Link: &notion.Link{URL: link}}
+			}
+		case word.Type == notion.RichTextTypeMention && word.Mention != nil && word.Mention.Page != nil:
+			if id, ok := ParseNotionID(word.Mention.Page.ID); ok && tm.Links[id] != "" {
+				word = notion.RichText{
+					Type:        notion.RichTextTypeText,
+					Annotations: word.Annotations,
+					Text:        &notion.Text{Content: word.PlainText, Link: &notion.Link{URL: tm.Links[id]}},
+				}
+			}
+		}
+		buf.WriteString(ConvertRich(word))
+	}
+
+	return buf.String()
+}
+
+// childDatabase returns the child database of the block with the links of its entries, or nil if not exported.
+func (tm *ToMarkdown) childDatabase(blockID string) *ChildDatabase {
+	id, _ := ParseNotionID(blockID)
+	db, ok := tm.ChildDatabases[id]
+	if !ok {
+		return nil
+	}
+
+	rows := make([]ChildDocument, 0, len(db.Rows))
+	for _, row := range db.Rows {
+		rowID, _ := ParseNotionID(row.ID)
+		row.Link = tm.Links[rowID]
+		rows = append(rows, row)
+	}
+	db.Rows = rows
+	return &db
+}
+
+// tableCell formats the property value as the content of a markdown table cell.
+func tableCell(v interface{}) string {
+	var s string
+	switch v := v.(type) {
+	case nil:
+	case []string:
+		s = strings.Join(v, ", ")
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	return strings.ReplaceAll(strings.ReplaceAll(s, "|", `\|`), "\n", " ")
+}
@@ -0,0 +1,41 @@
+/* global hexo */
+'use strict';
+
+// The tags of the blocks rendered by notion-md-gen with the hexo shortcodeSyntax.
+// Remove the note tag if the theme provides one, e.g. NexT.
+
+const { escapeHTML } = require('hexo-util');
+
+const markdown = text => hexo.render.renderSync({ text, engine: 'markdown' });
+
+// {% bookmark URL IMAGE TITLE %}DESCRIPTION{% endbookmark %}, the image is missing if the page has none
+hexo.extend.tag.register('bookmark', (args, content) => {
+  const [url, ...rest] = args;
+  const img = /^(https?:)?\/\//.test(rest[0] || '') ? rest.shift() : '';
+  const title = rest.join(' ') || url;
+  return `<a class="notion-bookmark" href="${escapeHTML(url)}" target="_blank" rel="noopener" style="display: flex; gap: 1em; padding: 0.75em; border: 1px solid rgba(127, 127, 127, 0.3); border-radius: 4px; text-decoration: none; color: inherit;">`
+    + (img ? `<img class="notion-bookmark-image" src="${escapeHTML(img)}" alt="" style="width: 8em; object-fit: cover;">` : '')
+    + '<span class="notion-bookmark-text">'
+    + `<strong class="notion-bookmark-title">${escapeHTML(title)}</strong><br>`
+    + `<span class="notion-bookmark-description">${escapeHTML(content.trim())}</span><br>`
+    + `<small class="notion-bookmark-url">${escapeHTML(url)}</small>`
+    + '</span></a>';
+}, { ends: true });
+
+// {% note EMOJI %}TEXT{% endnote %}
+hexo.extend.tag.register('note', (args, content) => {
+  return '<div class="notion-callout" style="display: flex; gap: 0.5em; padding: 1em; border-radius: 4px; background: rgba(127, 127, 127, 0.1);">'
+    + `<span class="notion-callout-icon">${escapeHTML(args.join(' '))}</span>`
+    + `<div class="notion-callout-text">${markdown(content)}</div>`
+    + '</div>';
+}, { ends: true });
+
+// {% columns %}{% column RATIO %}TEXT{% endcolumn %}...{% endcolumns %}, with the shortcode columnLayout
+hexo.extend.tag.register('columns', (args, content) => {
+  return `<div class="notion-columns" style="display: flex; flex-wrap: wrap; gap: 1em;">${content}</div>`;
+}, { ends: true });
+
+hexo.extend.tag.register('column', (args, content) => {
+  const ratio = parseFloat(args[0]) || 1;
+  return `<div class="notion-column" style="flex: ${ratio} 1 0; min-width: 15em;">${markdown(content)}</div>`;
+}, { ends: true });
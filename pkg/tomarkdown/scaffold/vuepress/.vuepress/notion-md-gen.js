@@ -0,0 +1,36 @@
+// The containers of the blocks rendered by notion-md-gen with the vuepress shortcodeSyntax,
+// add them to the plugins of .vuepress/config.js:
+//
+//   plugins: [...require('./notion-md-gen')]
+//
+// The callouts are the tip containers and [[toc]] the table of contents of the default theme.
+
+const escapeHTML = s => s.replace(/[&<>"']/g, c => `&#${c.charCodeAt(0)};`);
+
+module.exports = [
+  // ::: bookmark URL IMAGE TITLE, the image is missing if the page has none
+  ['container', {
+    type: 'bookmark',
+    before: info => {
+      const [url, ...rest] = info.split(/\s+/);
+      const img = /^(https?:)?\/\//.test(rest[0] || '') ? rest.shift() : '';
+      const title = rest.join(' ') || url;
+      return `<a class="notion-bookmark" href="${escapeHTML(url)}" target="_blank" rel="noopener" style="display: flex; gap: 1em; padding: 0.75em; border: 1px solid rgba(127, 127, 127, 0.3); border-radius: 4px; text-decoration: none; color: inherit;">`
+        + (img ? `<img class="notion-bookmark-image" src="${escapeHTML(img)}" alt="" style="width: 8em; object-fit: cover;">` : '')
+        + `<div class="notion-bookmark-text"><strong class="notion-bookmark-title">${escapeHTML(title)}</strong>`
+        + `<small class="notion-bookmark-url">${escapeHTML(url)}</small><div class="notion-bookmark-description">`;
+    },
+    after: () => '</div></div></a>',
+  }],
+  // :::: columns with ::: column RATIO inside, with the shortcode columnLayout
+  ['container', {
+    type: 'columns',
+    before: () => '<div class="notion-columns" style="display: flex; flex-wrap: wrap; gap: 1em;">',
+    after: () => '</div>',
+  }],
+  ['container', {
+    type: 'column',
+    before: info => `<div class="notion-column" style="flex: ${parseFloat(info) || 1} 1 0; min-width: 15em;">`,
+    after: () => '</div>',
+  }],
+];
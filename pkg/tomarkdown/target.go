@@ -0,0 +1,149 @@
+package tomarkdown
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"sync"
+
+	"github.com/dstotijn/go-notion"
+)
+
+// Target is a static site generator the extended syntax is rendered for, e.g. the shortcodes of hugo.
+type Target struct {
+	Name string
+	// Blocks are the blocks rendered by the templates of the target.
+	// The blocks without a default template, e.g. the callouts, are skipped by the targets which don't declare them.
+	Blocks []notion.BlockType
+	// Templates holds the <block type>.gohtml template of every declared block. It's parsed after the default template
+	// of the block, so it either replaces it or only redefines the templates it calls, e.g. "shortcode" of the columns.
+	Templates fs.FS
+	// Scaffold optionally holds the files implementing the shortcodes of the templates, by their paths in the site.
+	Scaffold fs.FS
+	// ScaffoldNote tells how to enable the scaffolded files in the site.
+	ScaffoldNote string
+}
+
+// Validate checks that the target has the templates of its blocks.
+func (t Target) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("the name of the target is required")
+	}
+	if t.Templates == nil && len(t.Blocks) > 0 {
+		return fmt.Errorf("target %s: the templates of the blocks are required", t.Name)
+	}
+	for _, bType := range t.Blocks {
+		if _, err := fs.Stat(t.Templates, fmt.Sprintf("%s.gohtml", bType)); err != nil {
+			return fmt.Errorf("target %s: no template of the block %s", t.Name, bType)
+		}
+	}
+
+	return nil
+}
+
+// renders returns true if the target declares the block.
+func (t *Target) renders(bType notion.BlockType) bool {
+	for _, blockType := range t.Blocks {
+		if blockType == bType {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	targetsMu   sync.RWMutex
+	targets     = make(map[string]*Target)
+	targetNames []string // in the order of the registration
+)
+
+// RegisterTarget makes the target available to EnableExtendedSyntax by its name, for the whole process.
+// A registered target of the same name is replaced, e.g. to customize the templates of a built-in one.
+func RegisterTarget(target Target) error {
+	if err := target.Validate(); err != nil {
+		return err
+	}
+
+	targetsMu.Lock()
+	defer targetsMu.Unlock()
+	if _, ok := targets[target.Name]; !ok {
+		targetNames = append(targetNames, target.Name)
+	}
+	targets[target.Name] = &target
+	return nil
+}
+
+// LookupTarget returns the registered target of the name.
+func LookupTarget(name string) (Target, bool) {
+	targetsMu.RLock()
+	defer targetsMu.RUnlock()
+	target, ok := targets[name]
+	if !ok {
+		return Target{}, false
+	}
+
+	return *target, true
+}
+
+// TargetNames returns the names of the registered targets, the built-in ones first.
+func TargetNames() []string {
+	targetsMu.RLock()
+	defer targetsMu.RUnlock()
+	return append([]string{}, targetNames...)
+}
+
+// targetBlock returns true if one of the registered targets declares the block.
+func targetBlock(bType notion.BlockType) bool {
+	targetsMu.RLock()
+	defer targetsMu.RUnlock()
+	for _, target := range targets {
+		if target.renders(bType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scaffoldFS holds the implementations of the shortcodes of the built-in targets.
+// The files of the dot directories are listed as the directories skip them.
+//
+//go:embed scaffold
+//go:embed scaffold/vuepress/.vuepress/notion-md-gen.js
+var scaffoldFS embed.FS
+
+// builtinTargets are the targets rendered by the templates/<name> directories.
+var builtinTargets = []Target{
+	{
+		Name: "hugo",
+		Blocks: []notion.BlockType{
+			notion.BlockTypeBookmark, notion.BlockTypeCallout, notion.BlockTypeColumnList, notion.BlockTypeColumn,
+			notion.BlockTypeImage, notion.BlockTypeTableOfContents,
+		},
+		ScaffoldNote: "The callouts and the columns render markdown in html, set markup.goldmark.renderer.unsafe to true in the config of hugo.",
+	},
+	{
+		Name:         "hexo",
+		Blocks:       []notion.BlockType{notion.BlockTypeBookmark, notion.BlockTypeCallout, notion.BlockTypeColumnList, notion.BlockTypeColumn},
+		ScaffoldNote: "Remove the note tag of scripts/notion-md-gen.js if the theme provides one.",
+	},
+	{
+		Name: "vuepress",
+		Blocks: []notion.BlockType{
+			notion.BlockTypeBookmark, notion.BlockTypeCallout, notion.BlockTypeColumnList, notion.BlockTypeColumn,
+			notion.BlockTypeTableOfContents,
+		},
+		ScaffoldNote: "Add the containers to the plugins of .vuepress/config.js: plugins: [...require('./notion-md-gen')]",
+	},
+}
+
+func init() {
+	for _, target := range builtinTargets {
+		target.Templates, _ = fs.Sub(mdTemplatesFS, "templates/"+target.Name)
+		target.Scaffold, _ = fs.Sub(scaffoldFS, "scaffold/"+target.Name)
+		if err := RegisterTarget(target); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package tomarkdown
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/dstotijn/go-notion"
+)
+
+// Heading is an entry of the table of contents.
+type Heading struct {
+	Level  int
+	Text   string
+	Anchor string
+}
+
+// Breadcrumb is an entry of the link trail from the root to the current page.
+type Breadcrumb struct {
+	Title string
+	URL   string
+}
+
+// collectHeadings returns all the headings of the blocks tree, in document order.
+func collectHeadings(blocks []notion.Block) []Heading {
+	headings := make([]Heading, 0)
+	anchors := make(map[string]int)
+	var walk func(blocks []notion.Block)
+	walk = func(blocks []notion.Block) {
+		for _, block := range blocks {
+			var level int
+			var text []notion.RichText
+			switch {
+			case block.Type == notion.BlockTypeHeading1 && block.Heading1 != nil:
+				level, text = 1, block.Heading1.Text
+			case block.Type == notion.BlockTypeHeading2 && block.Heading2 != nil:
+				level, text = 2, block.Heading2.Text
+			case block.Type == notion.BlockTypeHeading3 && block.Heading3 != nil:
+				level, text = 3, block.Heading3.Text
+			default:
+				walk(ChildrenBlocks(block))
+				continue
+			}
+
+			plain := ConvertPlainText(text)
+			anchor := slugify(plain)
+			if n := anchors[anchor]; n > 0 {
+				anchors[anchor]++
+				anchor = fmt.Sprintf("%s-%d", anchor, n)
+			} else {
+				anchors[anchor] = 1
+			}
+			headings = append(headings, Heading{Level: level, Text: plain, Anchor: anchor})
+		}
+	}
+	walk(blocks)
+
+	return headings
+}
+
+// hasBlock returns true if the blocks tree has a block of the type.
+func hasBlock(blocks []notion.Block, bType notion.BlockType) bool {
+	for _, block := range blocks {
+		if block.Type == bType || hasBlock(ChildrenBlocks(block), bType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// slugify generates the heading anchor the same way as GitHub and most markdown renderers do.
+func slugify(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '-', r == '_':
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			b.WriteRune('-')
+		}
+	}
+
+	return b.String()
+}
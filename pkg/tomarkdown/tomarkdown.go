@@ -4,16 +4,19 @@ import (
 	"bytes"
 	"embed"
 	"fmt"
+	"html"
 	"io"
+	"io/fs"
 	"net/http"
 	"net/url"
-	"os"
 	"path/filepath"
 	"strings"
 	"text/template"
 	"time"
 
 	"github.com/Masterminds/sprig"
+	"github.com/bonaysoft/notion-md-gen/pkg/logger"
+	"github.com/bonaysoft/notion-md-gen/pkg/storage"
 	"github.com/dstotijn/go-notion"
 	"github.com/otiai10/opengraph"
 	"gopkg.in/yaml.v3"
@@ -23,15 +26,11 @@ import (
 var mdTemplatesFS embed.FS
 
 var (
-	extendedSyntaxBlocks            = []notion.BlockType{notion.BlockTypeBookmark, notion.BlockTypeCallout}
-	blockTypeInExtendedSyntaxBlocks = func(bType notion.BlockType) bool {
-		for _, blockType := range extendedSyntaxBlocks {
-			if blockType == bType {
-				return true
-			}
-		}
-
-		return false
+	// containerBlocks only group their children, so the children are rendered at the same depth.
+	containerBlocks = map[notion.BlockType]bool{
+		notion.BlockTypeColumnList:  true,
+		notion.BlockTypeColumn:      true,
+		notion.BlockTypeSyncedBlock: true,
 	}
 )
 
@@ -48,28 +47,65 @@ type ToMarkdown struct {
 	ImgVisitPath    string
 	ContentTemplate string
 
-	extra map[string]interface{}
+	// Storage saves the downloaded images, default is the local ImgSavePath.
+	Storage storage.Storage
+	// Breadcrumbs is the link trail rendered by the breadcrumb block, it renders nothing if empty.
+	Breadcrumbs []Breadcrumb
+	// ColumnLayout is how the column_list blocks are rendered: flatten (default), flex or shortcode.
+	ColumnLayout string
+	// Links maps the IDs of the exported pages to the links of their documents, see ParseNotionID.
+	Links map[string]string
+	// ChildDatabases holds the exported entries of the child_database blocks, keyed like Links.
+	ChildDatabases map[string]ChildDatabase
+	// ChildDatabaseLayout is how the child_database blocks are rendered: list (default) or table.
+	ChildDatabaseLayout string
+	// ColumnRatios holds the width ratios of the column blocks, keyed like Links.
+	ColumnRatios map[string]float64
+
+	extra   map[string]interface{}
+	target  *Target
+	targets []Target // the targets given to EnableExtendedSyntax
 }
 
 func New() *ToMarkdown {
 	return &ToMarkdown{
 		FrontMatter:   make(map[string]interface{}),
 		ContentBuffer: new(bytes.Buffer),
-		extra:         make(map[string]interface{}),
+		extra: map[string]interface{}{
+			"ExtendedSyntaxEnabled": false,
+			"ExtendedSyntaxTarget":  "",
+		},
 	}
 }
 
 func (tm *ToMarkdown) WithFrontMatter(page notion.Page) {
 	tm.injectFrontMatterCover(page.Cover)
-	pageProps := page.Properties.(notion.DatabasePageProperties)
-	for fmKey, property := range pageProps {
-		tm.injectFrontMatter(fmKey, property)
+	switch pageProps := page.Properties.(type) {
+	case notion.DatabasePageProperties:
+		for fmKey, property := range pageProps {
+			tm.injectFrontMatter(fmKey, property)
+		}
+	case notion.PageProperties:
+		tm.FrontMatter["title"] = ConvertRichText(pageProps.Title.Title)
 	}
 }
 
-func (tm *ToMarkdown) EnableExtendedSyntax(target string) {
+// EnableExtendedSyntax renders the blocks with the templates of the target, see RegisterTarget.
+// The given targets, e.g. the ones of a config, take precedence over the registered ones of the same names.
+func (tm *ToMarkdown) EnableExtendedSyntax(target string, targets ...Target) {
 	tm.extra["ExtendedSyntaxEnabled"] = true
 	tm.extra["ExtendedSyntaxTarget"] = target
+	tm.target = nil
+	tm.targets = targets
+	for i := range targets {
+		if targets[i].Name == target {
+			tm.target = &targets[i]
+			return
+		}
+	}
+	if t, ok := LookupTarget(target); ok {
+		tm.target = &t
+	}
 }
 
 func (tm *ToMarkdown) ExtendedSyntaxEnabled() bool {
@@ -80,15 +116,45 @@ func (tm *ToMarkdown) ExtendedSyntaxEnabled() bool {
 	return false
 }
 
+// shouldSkipRender returns true for the blocks only rendered by the other targets, e.g. the callouts without a target.
 func (tm *ToMarkdown) shouldSkipRender(bType notion.BlockType) bool {
-	return !tm.ExtendedSyntaxEnabled() && blockTypeInExtendedSyntaxBlocks(bType)
+	if tm.target != nil && tm.target.renders(bType) {
+		return false
+	}
+
+	if hasDefaultTemplate(bType) {
+		return false
+	}
+	for _, target := range tm.targets {
+		if target.renders(bType) {
+			return true
+		}
+	}
+	return targetBlock(bType)
+}
+
+// hasDefaultTemplate returns true if the block is rendered without a target.
+func hasDefaultTemplate(bType notion.BlockType) bool {
+	_, err := fs.Stat(mdTemplatesFS, fmt.Sprintf("templates/%s.gohtml", bType))
+	return err == nil
 }
 
 func (tm *ToMarkdown) GenerateTo(blocks []notion.Block, writer io.Writer) error {
+	if tm.ExtendedSyntaxEnabled() && tm.target == nil {
+		return fmt.Errorf("unknown target of the extended syntax: %s", tm.extra["ExtendedSyntaxTarget"])
+	}
 	if err := tm.GenFrontMatter(writer); err != nil {
 		return err
 	}
 
+	tm.extra["Headings"] = []Heading{}
+	if hasBlock(blocks, notion.BlockTypeTableOfContents) {
+		tm.extra["Headings"] = collectHeadings(blocks)
+	}
+	tm.extra["Breadcrumbs"] = tm.Breadcrumbs
+	tm.extra["ColumnLayout"] = tm.ColumnLayout
+	tm.extra["ChildDatabaseLayout"] = tm.ChildDatabaseLayout
+
 	if err := tm.GenContentBlocks(blocks, 0); err != nil {
 		return err
 	}
@@ -153,6 +219,13 @@ func (tm *ToMarkdown) GenContentBlocks(blocks []notion.Block, depth int) error {
 			err = tm.downloadImage(block.Image)
 		case notion.BlockTypeBookmark:
 			err = tm.injectBookmarkInfo(block.Bookmark, &mdb.Extra)
+		case notion.BlockTypeColumn:
+			mdb.Extra["ColumnRatio"] = tm.columnRatio(block.ID, len(blocks))
+		case notion.BlockTypeChildPage:
+			id, _ := ParseNotionID(block.ID)
+			mdb.Extra["Link"] = tm.Links[id]
+		case notion.BlockTypeChildDatabase:
+			mdb.Extra["Database"] = tm.childDatabase(block.ID)
 		}
 		if err != nil {
 			return err
@@ -167,14 +240,33 @@ func (tm *ToMarkdown) GenContentBlocks(blocks []notion.Block, depth int) error {
 	return nil
 }
 
+// columnRatio returns the width ratio of the column. The columns whose ratio Notion doesn't return share the width evenly.
+func (tm *ToMarkdown) columnRatio(blockID string, columns int) float64 {
+	if id, ok := ParseNotionID(blockID); ok && tm.ColumnRatios[id] > 0 {
+		return tm.ColumnRatios[id]
+	}
+	return 1 / float64(columns)
+}
+
 func (tm *ToMarkdown) GenBlock(bType notion.BlockType, block MdBlock) error {
 	funcs := sprig.TxtFuncMap()
 	funcs["deref"] = func(i *bool) bool { return *i }
-	funcs["rich2md"] = ConvertRichText
-	t := template.New(fmt.Sprintf("%s.gohtml", bType)).Funcs(funcs)
-	tpl, err := t.ParseFS(mdTemplatesFS, fmt.Sprintf("templates/%s.*", bType))
-	if err != nil {
-		return err
+	funcs["rich2md"] = tm.convertRichText
+	funcs["cell"] = tableCell
+	funcs["rich2plain"] = ConvertPlainText
+	funcs["rich2html"] = ConvertRichTextHTML
+	tpl := template.New(fmt.Sprintf("%s.gohtml", bType)).Funcs(funcs)
+	targetRenders := tm.target != nil && tm.target.renders(bType)
+	if hasDefaultTemplate(bType) || !targetRenders {
+		if _, err := tpl.ParseFS(mdTemplatesFS, fmt.Sprintf("templates/%s.*", bType)); err != nil {
+			return err
+		}
+	}
+	// the template of the target replaces the default one, or only redefines the templates it calls
+	if targetRenders {
+		if _, err := tpl.ParseFS(tm.target.Templates, fmt.Sprintf("%s.gohtml", bType)); err != nil {
+			return err
+		}
 	}
 
 	if err := tpl.Execute(tm.ContentBuffer, block); err != nil {
@@ -182,8 +274,18 @@ func (tm *ToMarkdown) GenBlock(bType notion.BlockType, block MdBlock) error {
 	}
 
 	if block.HasChildren {
-		block.Depth++
-		return tm.GenContentBlocks(getChildrenBlocks(block), block.Depth)
+		depth := block.Depth + 1
+		if containerBlocks[bType] {
+			depth = block.Depth
+		}
+		if err := tm.GenContentBlocks(ChildrenBlocks(block.Block), depth); err != nil {
+			return err
+		}
+	}
+
+	// The optional "end" template closes the block after its children, e.g. a html tag or shortcode.
+	if end := tpl.Lookup("end"); end != nil {
+		return end.Execute(tm.ContentBuffer, block)
 	}
 
 	return nil
@@ -195,13 +297,9 @@ func (tm *ToMarkdown) downloadImage(image *notion.FileBlock) error {
 		if err != nil {
 			return "", err
 		}
+		defer resp.Body.Close()
 
-		imgFilename, err := tm.saveTo(resp.Body, imgURL, tm.ImgSavePath)
-		if err != nil {
-			return "", err
-		}
-
-		return filepath.Join(tm.ImgVisitPath, imgFilename), nil
+		return tm.saveTo(resp.Body, imgURL)
 	}
 
 	var err error
@@ -215,7 +313,7 @@ func (tm *ToMarkdown) downloadImage(image *notion.FileBlock) error {
 	return err
 }
 
-func (tm *ToMarkdown) saveTo(reader io.Reader, rawURL, distDir string) (string, error) {
+func (tm *ToMarkdown) saveTo(reader io.Reader, rawURL string) (string, error) {
 	u, err := url.Parse(rawURL)
 	if err != nil {
 		return "", fmt.Errorf("malformed url: %s", err)
@@ -228,19 +326,16 @@ func (tm *ToMarkdown) saveTo(reader io.Reader, rawURL, distDir string) (string,
 		imageFilename = splitPaths[len(splitPaths)-2] + filepath.Ext(u.Path)
 	}
 
-	if err := os.MkdirAll(distDir, 0755); err != nil {
-		return "", fmt.Errorf("%s: %s", distDir, err)
-	}
-
 	filename := fmt.Sprintf("%s_%s", u.Hostname(), imageFilename)
-	out, err := os.Create(filepath.Join(distDir, filename))
-	if err != nil {
-		return "", fmt.Errorf("couldn't create image file: %s", err)
+	return tm.storage().Save(filename, reader)
+}
+
+func (tm *ToMarkdown) storage() storage.Storage {
+	if tm.Storage != nil {
+		return tm.Storage
 	}
-	defer out.Close()
 
-	_, err = io.Copy(out, reader)
-	return filename, err
+	return storage.NewLocal(tm.ImgSavePath, tm.ImgVisitPath)
 }
 
 // injectBookmarkInfo set bookmark info into the extra map field
@@ -263,10 +358,23 @@ func (tm *ToMarkdown) injectBookmarkInfo(bookmark *notion.Bookmark, extra *map[s
 
 // injectFrontMatter convert the prop to the front-matter
 func (tm *ToMarkdown) injectFrontMatter(key string, property notion.DatabasePageProperty) {
+	fmv := PropertyValue(property)
+	if fmv == nil {
+		return
+	}
+
+	// todo support settings mapping relation
+	tm.FrontMatter[key] = fmv
+}
+
+// PropertyValue converts the database page property to a plain value, it returns nil if the property is empty or unsupported.
+func PropertyValue(property notion.DatabasePageProperty) interface{} {
 	var fmv interface{}
 	switch prop := property.Value().(type) {
 	case *notion.SelectOptions:
-		fmv = prop.Name
+		if prop != nil {
+			fmv = prop.Name
+		}
 	case []notion.SelectOptions:
 		opts := make([]string, 0)
 		for _, options := range prop {
@@ -276,25 +384,30 @@ func (tm *ToMarkdown) injectFrontMatter(key string, property notion.DatabasePage
 	case []notion.RichText:
 		fmv = ConvertRichText(prop)
 	case *time.Time:
-		fmv = prop.Format("2006-01-02T15:04:05+07:00")
+		if prop != nil {
+			fmv = prop.Format("2006-01-02T15:04:05+07:00")
+		}
 	case *notion.Date:
-		fmv = prop.Start.Format("2006-01-02T15:04:05+07:00")
+		if prop != nil {
+			fmv = prop.Start.Format("2006-01-02T15:04:05+07:00")
+		}
 	case *notion.User:
-		fmv = prop.Name
+		if prop != nil {
+			fmv = prop.Name
+		}
 	case *string:
-		fmv = *prop
+		if prop != nil {
+			fmv = *prop
+		}
 	case *float64:
-		fmv = *prop
+		if prop != nil {
+			fmv = *prop
+		}
 	default:
-		fmt.Printf("Unsupport prop: %s - %T\n", prop, prop)
+		logger.Debug("Unsupport prop", "type", property.Type, "value", fmt.Sprintf("%T", prop))
 	}
 
-	if fmv == nil {
-		return
-	}
-
-	// todo support settings mapping relation
-	tm.FrontMatter[key] = fmv
+	return fmv
 }
 
 func (tm *ToMarkdown) injectFrontMatterCover(cover *notion.Cover) {
@@ -344,6 +457,62 @@ func ConvertRich(t notion.RichText) string {
 	return ""
 }
 
+// ConvertPlainText returns the text without any formatting, e.g. for the alt text of an image.
+func ConvertPlainText(t []notion.RichText) string {
+	buf := &bytes.Buffer{}
+	for _, word := range t {
+		if word.PlainText == "" && word.Text != nil {
+			buf.WriteString(word.Text.Content)
+			continue
+		}
+		buf.WriteString(word.PlainText)
+	}
+
+	return buf.String()
+}
+
+// ConvertRichTextHTML converts the rich text to html, for the places markdown is not rendered, e.g. a figcaption.
+func ConvertRichTextHTML(t []notion.RichText) string {
+	buf := &bytes.Buffer{}
+	for _, word := range t {
+		if word.Type != notion.RichTextTypeText || word.Text == nil {
+			continue
+		}
+
+		content := html.EscapeString(word.Text.Content)
+		if word.Text.Link != nil {
+			content = fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(word.Text.Link.URL), content)
+		}
+		buf.WriteString(htmlEmph(word.Annotations, content))
+	}
+
+	return buf.String()
+}
+
+func htmlEmph(a *notion.Annotations, content string) string {
+	if a == nil {
+		return content
+	}
+
+	if a.Code {
+		content = "<code>" + content + "</code>"
+	}
+	if a.Bold {
+		content = "<strong>" + content + "</strong>"
+	}
+	if a.Italic {
+		content = "<em>" + content + "</em>"
+	}
+	if a.Strikethrough {
+		content = "<del>" + content + "</del>"
+	}
+	if a.Underline {
+		content = "<u>" + content + "</u>"
+	}
+
+	return content
+}
+
 func emphFormat(a *notion.Annotations) (s string) {
 	s = "%s"
 	if a == nil {
@@ -374,33 +543,39 @@ func emphFormat(a *notion.Annotations) (s string) {
 	return s
 }
 
-func getChildrenBlocks(block MdBlock) []notion.Block {
-	switch block.Type {
-	case notion.BlockTypeQuote:
+// ChildrenBlocks returns the children of the block, which are retrieved separately from the block.
+// It returns nil if the block has no children or its payload is missing, e.g. in a partial object.
+func ChildrenBlocks(block notion.Block) []notion.Block {
+	if !block.HasChildren {
+		return nil
+	}
+
+	switch {
+	case block.Type == notion.BlockTypeQuote && block.Quote != nil:
 		return block.Quote.Children
-	case notion.BlockTypeToggle:
+	case block.Type == notion.BlockTypeToggle && block.Toggle != nil:
 		return block.Toggle.Children
-	case notion.BlockTypeParagraph:
+	case block.Type == notion.BlockTypeParagraph && block.Paragraph != nil:
 		return block.Paragraph.Children
-	case notion.BlockTypeCallout:
+	case block.Type == notion.BlockTypeCallout && block.Callout != nil:
 		return block.Callout.Children
-	case notion.BlockTypeBulletedListItem:
+	case block.Type == notion.BlockTypeBulletedListItem && block.BulletedListItem != nil:
 		return block.BulletedListItem.Children
-	case notion.BlockTypeNumberedListItem:
+	case block.Type == notion.BlockTypeNumberedListItem && block.NumberedListItem != nil:
 		return block.NumberedListItem.Children
-	case notion.BlockTypeToDo:
+	case block.Type == notion.BlockTypeToDo && block.ToDo != nil:
 		return block.ToDo.Children
-	case notion.BlockTypeCode:
+	case block.Type == notion.BlockTypeCode && block.Code != nil:
 		return block.Code.Children
-	case notion.BlockTypeColumn:
+	case block.Type == notion.BlockTypeColumn && block.Column != nil:
 		return block.Column.Children
-	case notion.BlockTypeColumnList:
+	case block.Type == notion.BlockTypeColumnList && block.ColumnList != nil:
 		return block.ColumnList.Children
-	case notion.BlockTypeTable:
+	case block.Type == notion.BlockTypeTable && block.Table != nil:
 		return block.Table.Children
-	case notion.BlockTypeSyncedBlock:
+	case block.Type == notion.BlockTypeSyncedBlock && block.SyncedBlock != nil:
 		return block.SyncedBlock.Children
-	case notion.BlockTypeTemplate:
+	case block.Type == notion.BlockTypeTemplate && block.Template != nil:
 		return block.Template.Children
 	}
 
@@ -1,15 +1,22 @@
 package tomarkdown
 
 import (
+	"bytes"
 	"embed"
 	_ "embed"
 	"encoding/json"
 	"fmt"
 	"io/fs"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"regexp"
+	"strings"
 	"testing"
+	"testing/fstest"
 
+	"github.com/bonaysoft/notion-md-gen/schema"
 	"github.com/dstotijn/go-notion"
 	"github.com/stretchr/testify/assert"
 )
@@ -48,3 +55,288 @@ func TestAllTarget(t *testing.T) {
 		})
 	}
 }
+
+func TestImageCaption(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("png"))
+	}))
+	defer server.Close()
+
+	caption := []notion.RichText{
+		{Type: notion.RichTextTypeText, PlainText: "The ", Text: &notion.Text{Content: "The "}},
+		{Type: notion.RichTextTypeText, PlainText: "beige", Text: &notion.Text{Content: "beige", Link: &notion.Link{URL: "https://a.com"}}, Annotations: &notion.Annotations{Bold: true}},
+		{Type: notion.RichTextTypeText, PlainText: ` "cover"`, Text: &notion.Text{Content: ` "cover"`}},
+	}
+	image := func() []notion.Block {
+		return []notion.Block{{Type: notion.BlockTypeImage, Image: &notion.FileBlock{
+			Type:     notion.FileTypeExternal,
+			External: &notion.FileExternal{URL: server.URL + "/a.png"},
+			Caption:  caption,
+		}}}
+	}
+
+	tom := New()
+	tom.ImgSavePath = t.TempDir()
+	tom.ImgVisitPath = "/images"
+	buf := new(bytes.Buffer)
+	assert.NoError(t, tom.GenerateTo(image(), buf))
+	assert.Equal(t, `<figure>
+  <img src="/images/127.0.0.1_a.png" alt="The beige &#34;cover&#34;">
+  <figcaption>The <strong><a href="https://a.com">beige</a></strong> &#34;cover&#34;</figcaption>
+</figure>
+`, buf.String())
+
+	tom = New()
+	tom.ImgSavePath = t.TempDir()
+	tom.ImgVisitPath = "/images"
+	tom.EnableExtendedSyntax("hugo")
+	buf = new(bytes.Buffer)
+	assert.NoError(t, tom.GenerateTo(image(), buf))
+	assert.Equal(t, `{{< figure src="/images/127.0.0.1_a.png" alt="The beige \"cover\"" caption="The **[beige](https://a.com)** \"cover\"" >}}`+"\n", buf.String())
+}
+
+func TestTableOfContents(t *testing.T) {
+	blocks := make([]notion.Block, 0)
+	blockBytes, err := testdatas.ReadFile("testdata/table_of_contents.json")
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(blockBytes, &blocks))
+
+	tom := New()
+	tom.Breadcrumbs = []Breadcrumb{{Title: "Docs", URL: "/docs/"}, {Title: "Getting Started"}}
+	buf := new(bytes.Buffer)
+	assert.NoError(t, tom.GenerateTo(blocks, buf))
+	assert.Equal(t, `[Docs](/docs/) / Getting Started
+- [Getting Started](#getting-started)
+    - [Install: the CLI](#install-the-cli)
+    - [Install: the CLI](#install-the-cli-1)
+# Getting Started
+Hello
+
+---
+## Install: the CLI
+## Install: the CLI
+`, buf.String())
+
+	tom = New()
+	tom.EnableExtendedSyntax("vuepress")
+	buf = new(bytes.Buffer)
+	assert.NoError(t, tom.GenerateTo(blocks, buf))
+	assert.Contains(t, buf.String(), "[[toc]]\n")
+	assert.NotContains(t, buf.String(), " / ")
+}
+
+func TestColumnList(t *testing.T) {
+	blockBytes, err := testdatas.ReadFile("testdata/column_list.json")
+	assert.NoError(t, err)
+
+	var ratios map[string]float64
+	render := func(layout, target string) string {
+		blocks := make([]notion.Block, 0)
+		assert.NoError(t, json.Unmarshal(blockBytes, &blocks))
+		tom := New()
+		tom.ColumnLayout = layout
+		tom.ColumnRatios = ratios
+		tom.EnableExtendedSyntax(target)
+		buf := new(bytes.Buffer)
+		assert.NoError(t, tom.GenerateTo(blocks, buf))
+		return buf.String()
+	}
+
+	assert.Equal(t, "- left\nright\n", render("", "hugo"))
+	assert.Equal(t, `<div style="display: flex; flex-wrap: wrap; gap: 1em;">
+<div style="flex: 0.5 1 0; min-width: 15em;">
+
+- left
+
+</div>
+<div style="flex: 0.5 1 0; min-width: 15em;">
+
+right
+
+</div>
+</div>
+`, render("flex", "hugo"))
+	assert.Equal(t, ":::: columns\n::: column 0.5\n- left\n:::\n::: column 0.5\nright\n:::\n::::\n", render("shortcode", "vuepress"))
+
+	// The widths of the columns in Notion
+	ratios = map[string]float64{"2f4b7c3e1a9d4c6e8b2a5d7e9f0a1b2c": 0.25, "8c1d2e3f4a5b4c6d9e7f0a1b2c3d4e5f": 0.75}
+	assert.Equal(t, ":::: columns\n::: column 0.25\n- left\n:::\n::: column 0.75\nright\n:::\n::::\n", render("shortcode", "vuepress"))
+}
+
+func TestParseNotionID(t *testing.T) {
+	const id = "1a2b3c4d5e6f47a8b9c0d1e2f3a4b5c6"
+	for _, s := range []string{
+		id,
+		"1a2b3c4d-5e6f-47a8-b9c0-d1e2f3a4b5c6",
+		" 1A2B3C4D5E6F47A8B9C0D1E2F3A4B5C6 ",
+		"https://www.notion.so/" + id,
+		"https://www.notion.so/My-Page-" + id,
+		"https://www.notion.so/workspace/My-Page-" + id + "?pvs=4",
+		"https://workspace.notion.site/My-Page-" + id + "#anchor",
+		"notion.so/workspace/" + id + "/",
+		"https://www.notion.so/workspace/ffffffffffffffffffffffffffffffff?v=eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee&p=" + id,
+	} {
+		parsed, ok := ParseNotionID(s)
+		assert.True(t, ok, s)
+		assert.Equal(t, id, parsed, s)
+	}
+
+	for _, s := range []string{"", "not-an-id", "https://www.notion.so/My-Page", "zzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzz"} {
+		_, ok := ParseNotionID(s)
+		assert.False(t, ok, s)
+	}
+}
+
+func TestScaffold(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><meta property="og:title" content="Example Domain"><meta property="og:image" content="/a.png"></head></html>`))
+	}))
+	defer server.Close()
+
+	// the shortcodes rendered by the templates, the built-in ones of the targets aren't scaffolded
+	shortcodes := map[string]*regexp.Regexp{
+		"hugo":     regexp.MustCompile(`\{\{[%<] ([a-z]+)`),
+		"hexo":     regexp.MustCompile(`\{% ([a-z]+)`),
+		"vuepress": regexp.MustCompile(`(?m)^:{3,} ([a-z]+)`),
+	}
+	defined := map[string]func(files fs.FS, name string) bool{
+		"hugo": func(files fs.FS, name string) bool {
+			_, err := fs.Stat(files, "layouts/shortcodes/"+name+".html")
+			return err == nil || name == "figure"
+		},
+		"hexo": func(files fs.FS, name string) bool {
+			script, err := fs.ReadFile(files, "scripts/notion-md-gen.js")
+			return err == nil && (strings.HasPrefix(name, "end") || bytes.Contains(script, []byte("register('"+name+"'")))
+		},
+		"vuepress": func(files fs.FS, name string) bool {
+			plugins, err := fs.ReadFile(files, ".vuepress/notion-md-gen.js")
+			return err == nil && (bytes.Contains(plugins, []byte("type: '"+name+"'")) || name == "tip")
+		},
+	}
+
+	for _, target := range TargetNames() {
+		files := targets[target].Scaffold
+
+		tom := New()
+		tom.ImgSavePath = t.TempDir()
+		tom.ColumnLayout = "shortcode"
+		tom.EnableExtendedSyntax(target)
+		blocks := []notion.Block{{Type: notion.BlockTypeBookmark, Bookmark: &notion.Bookmark{URL: server.URL}}}
+		for _, name := range []string{"callout", "column_list", "table_of_contents"} {
+			blockBytes, err := testdatas.ReadFile("testdata/" + name + ".json")
+			assert.NoError(t, err)
+			more := make([]notion.Block, 0)
+			assert.NoError(t, json.Unmarshal(blockBytes, &more))
+			blocks = append(blocks, more...)
+		}
+		buf := new(bytes.Buffer)
+		assert.NoError(t, tom.GenerateTo(blocks, buf))
+
+		matches := shortcodes[target].FindAllStringSubmatch(buf.String(), -1)
+		assert.NotEmpty(t, matches, target)
+		for _, match := range matches {
+			assert.True(t, defined[target](files, match[1]), "%s: the %s shortcode isn't scaffolded", target, match[1])
+		}
+	}
+}
+
+func TestRegisterTarget(t *testing.T) {
+	// the enum of the shortcodeSyntax in the JSON Schema lists the built-in targets
+	var doc struct {
+		Definitions map[string]struct {
+			Properties map[string]struct {
+				AnyOf []struct {
+					Enum []string
+				}
+			}
+		}
+	}
+	assert.NoError(t, json.Unmarshal(schema.JSON, &doc))
+	assert.Equal(t, append([]string{""}, TargetNames()...), doc.Definitions["markdown"].Properties["shortcodeSyntax"].AnyOf[0].Enum)
+
+	assert.EqualError(t, RegisterTarget(Target{Name: "docs", Blocks: []notion.BlockType{notion.BlockTypeCallout}, Templates: fstest.MapFS{}}),
+		"target docs: no template of the block callout")
+	assert.NoError(t, RegisterTarget(Target{
+		Name:   "docs",
+		Blocks: []notion.BlockType{notion.BlockTypeCallout, notion.BlockTypeTableOfContents},
+		Templates: fstest.MapFS{
+			"callout.gohtml":           {Data: []byte(":::note {{.Callout.Icon.Emoji}}\n{{rich2md .Callout.Text}}\n:::\n")},
+			"table_of_contents.gohtml": {Data: []byte(`{{define "toc"}}<TOCInline toc={toc} />{{"\n"}}{{end}}`)},
+		},
+	}))
+	defer func() {
+		delete(targets, "docs")
+		targetNames = targetNames[:len(targetNames)-1]
+	}()
+	assert.Equal(t, []string{"hugo", "hexo", "vuepress", "docs"}, TargetNames())
+
+	render := func(target string, names ...string) (string, error) {
+		blocks := make([]notion.Block, 0)
+		for _, name := range names {
+			blockBytes, err := testdatas.ReadFile("testdata/" + name + ".json")
+			assert.NoError(t, err)
+			more := make([]notion.Block, 0)
+			assert.NoError(t, json.Unmarshal(blockBytes, &more))
+			blocks = append(blocks, more...)
+		}
+		tom := New()
+		if target != "" {
+			tom.EnableExtendedSyntax(target)
+		}
+		buf := new(bytes.Buffer)
+		err := tom.GenerateTo(blocks, buf)
+		return buf.String(), err
+	}
+
+	out, err := render("docs", "callout", "table_of_contents")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(out, ":::note ⭐\nLacinato kale\n:::\n<TOCInline toc={toc} />\n# Getting Started\n"), out)
+
+	// the callouts are skipped without a target which renders them
+	out, err = render("", "callout")
+	assert.NoError(t, err)
+	assert.Empty(t, out)
+
+	_, err = render("jekyll", "callout")
+	assert.EqualError(t, err, "unknown target of the extended syntax: jekyll")
+}
+
+func TestEnableExtendedSyntaxTargets(t *testing.T) {
+	blockBytes, err := testdatas.ReadFile("testdata/callout.json")
+	assert.NoError(t, err)
+	blocks := make([]notion.Block, 0)
+	assert.NoError(t, json.Unmarshal(blockBytes, &blocks))
+	render := func(target string, targets ...Target) string {
+		tom := New()
+		tom.EnableExtendedSyntax(target, targets...)
+		buf := new(bytes.Buffer)
+		assert.NoError(t, tom.GenerateTo(blocks, buf))
+		return buf.String()
+	}
+
+	hugo := Target{Name: "hugo", Blocks: []notion.BlockType{notion.BlockTypeCallout}, Templates: fstest.MapFS{
+		"callout.gohtml": {Data: []byte("> {{rich2md .Callout.Text}}\n")},
+	}}
+	builtin := render("hugo")
+	assert.Equal(t, "> Lacinato kale\n", render("hugo", hugo))
+	// the given targets are only used by the instance
+	assert.Equal(t, builtin, render("hugo"))
+	_, ok := LookupTarget("docs")
+	assert.False(t, ok)
+	docs := hugo
+	docs.Name = "docs"
+	assert.Equal(t, "> Lacinato kale\n", render("docs", docs))
+}
+
+func TestChildrenBlocksPartial(t *testing.T) {
+	// a partial object has children without their payload
+	blocks := []notion.Block{
+		{Type: notion.BlockTypeParagraph, HasChildren: true},
+		{Type: notion.BlockTypeTableOfContents, TableOfContents: &notion.TableOfContents{}},
+		{Type: notion.BlockTypeHeading1, HasChildren: true},
+	}
+	assert.Nil(t, ChildrenBlocks(blocks[0]))
+	assert.Equal(t, []Heading{}, collectHeadings(blocks))
+	assert.True(t, hasBlock(blocks, notion.BlockTypeTableOfContents))
+	assert.False(t, hasBlock(blocks[:1], notion.BlockTypeTableOfContents))
+}
@@ -0,0 +1,11 @@
+// Package schema publishes the JSON Schema of notion-md-gen.yaml, e.g. for the completion of the editors:
+//
+//	# yaml-language-server: $schema=https://raw.githubusercontent.com/bonaysoft/notion-md-gen/master/schema/notion-md-gen.schema.json
+package schema
+
+import _ "embed"
+
+// JSON is the JSON Schema of the config file.
+//
+//go:embed notion-md-gen.schema.json
+var JSON []byte